@@ -19,6 +19,8 @@ const minecraftAuthURL = `https://multiplayer.minecraft.net/authentication`
 // RequestMinecraftChain requests a fully processed Minecraft JWT chain using the XSTS token passed, and the
 // ECDSA private key of the client. This key will later be used to initialise encryption, and must be saved
 // for when packets need to be decrypted/encrypted.
+// The request is made using the *http.Client stored in the context passed under the oauth2.HTTPClient key,
+// if present.
 func RequestMinecraftChain(ctx context.Context, token *XBLToken, key *ecdsa.PrivateKey) (string, error) {
 	data, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
 
@@ -37,7 +39,11 @@ func RequestMinecraftChain(ctx context.Context, token *XBLToken, key *ecdsa.Priv
 	request.Header.Set("Client-Version", protocol.CurrentVersion)
 	request.Header.Set("Content-Type", "application/json")
 
-	c := &http.Client{}
+	c, ok := contextClient(ctx)
+	if !ok {
+		c = &http.Client{}
+		defer c.CloseIdleConnections()
+	}
 	resp, err := c.Do(request)
 	if err != nil {
 		return "", fmt.Errorf("POST %v: %w", minecraftAuthURL, err)
@@ -47,6 +53,5 @@ func RequestMinecraftChain(ctx context.Context, token *XBLToken, key *ecdsa.Priv
 		return "", fmt.Errorf("POST %v: %v", minecraftAuthURL, resp.Status)
 	}
 	data, err = io.ReadAll(resp.Body)
-	c.CloseIdleConnections()
 	return string(data), err
 }
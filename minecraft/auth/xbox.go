@@ -40,19 +40,24 @@ func (t XBLToken) SetAuthHeader(r *http.Request) {
 }
 
 // RequestXBLToken requests an XBOX Live auth token using the passed Live token pair.
+// The requests are made using the *http.Client stored in the context passed under the oauth2.HTTPClient key,
+// if present.
 func RequestXBLToken(ctx context.Context, liveToken *oauth2.Token, relyingParty string) (*XBLToken, error) {
 	if !liveToken.Valid() {
 		return nil, fmt.Errorf("live token is no longer valid")
 	}
-	c := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				Renegotiation:      tls.RenegotiateOnceAsClient,
-				InsecureSkipVerify: true,
+	c, ok := contextClient(ctx)
+	if !ok {
+		c = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					Renegotiation:      tls.RenegotiateOnceAsClient,
+					InsecureSkipVerify: true,
+				},
 			},
-		},
+		}
+		defer c.CloseIdleConnections()
 	}
-	defer c.CloseIdleConnections()
 
 	// We first generate an ECDSA private key which will be used to provide a 'ProofKey' to each of the
 	// requests, and to sign these requests.
@@ -67,6 +72,13 @@ func RequestXBLToken(ctx context.Context, liveToken *oauth2.Token, relyingParty
 	return obtainXBLToken(ctx, c, key, liveToken, deviceToken, relyingParty)
 }
 
+// contextClient returns the *http.Client stored in the context passed under the oauth2.HTTPClient key, as is
+// done by golang.org/x/oauth2. If no client is stored, contextClient returns false.
+func contextClient(ctx context.Context) (*http.Client, bool) {
+	c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client)
+	return c, ok && c != nil
+}
+
 func obtainXBLToken(ctx context.Context, c *http.Client, key *ecdsa.PrivateKey, liveToken *oauth2.Token, device *deviceToken, relyingParty string) (*XBLToken, error) {
 	data, err := json.Marshal(map[string]any{
 		"AccessToken":       "t=" + liveToken.AccessToken,
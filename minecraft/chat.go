@@ -0,0 +1,52 @@
+package minecraft
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// SendChat sends a chat message to the server, as if the player typed it in the chat. The packet.Text sent has
+// the TextTypeChat type and has the display name and XUID of the player as source. SendChat should only be
+// called on a Conn obtained using a Dialer. Commands should be executed using ExecuteCommand instead.
+func (conn *Conn) SendChat(message string) error {
+	return conn.WritePacket(&packet.Text{
+		TextType:   packet.TextTypeChat,
+		SourceName: conn.identityData.DisplayName,
+		Message:    message,
+		XUID:       conn.identityData.XUID,
+	})
+}
+
+// ChatMessage is a message received in a packet.Text, such as a chat message of a player, a whisper or a
+// system message. A ChatMessage may be obtained from a packet.Text using ParseChatMessage.
+type ChatMessage struct {
+	// Type is the type of the message, which is one of the packet.TextType constants.
+	Type byte
+	// Sender is the name of the sender of the message. It is only set for messages that have a sender, which
+	// are those of the types packet.TextTypeChat, packet.TextTypeWhisper and packet.TextTypeAnnouncement.
+	Sender string
+	// SenderXUID is the XUID of the player that sent the message, if known.
+	SenderXUID string
+	// Message is the message itself. If Translate is true, Message is a translation key that the client
+	// translates and fills out using Parameters.
+	Message string
+	// Parameters holds the parameters filled out in the translated Message. It is only set for messages of the
+	// types packet.TextTypeTranslation, packet.TextTypePopup and packet.TextTypeJukeboxPopup.
+	Parameters []string
+	// Translate specifies if Message should be translated by the client.
+	Translate bool
+}
+
+// ParseChatMessage returns the ChatMessage held by the packet.Text passed. Fields of the packet that are not
+// used by its type, such as the source name of system messages, are left empty in the ChatMessage returned.
+func ParseChatMessage(pk *packet.Text) ChatMessage {
+	msg := ChatMessage{Type: pk.TextType, SenderXUID: pk.XUID, Message: pk.Message, Translate: pk.NeedsTranslation}
+	switch pk.TextType {
+	case packet.TextTypeChat, packet.TextTypeWhisper, packet.TextTypeAnnouncement:
+		msg.Sender = pk.SourceName
+	case packet.TextTypeTranslation, packet.TextTypePopup, packet.TextTypeJukeboxPopup:
+		msg.Parameters = pk.Parameters
+		// Translatable text types are translated by the client regardless of NeedsTranslation.
+		msg.Translate = true
+	}
+	return msg
+}
@@ -0,0 +1,76 @@
+package minecraft
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// ExecuteCommand sends a packet.CommandRequest holding the command line passed, such as '/tp @s 0 0 0', to
+// the server and waits for the packet.CommandOutput that the server sends in response. The output holds the
+// success count and the success or error messages of the command. ExecuteCommand should only be called on a
+// Conn obtained using a Dialer.
+// The output is matched to the request using the UUID of the CommandOrigin, which servers echo back in the
+// CommandOutput. The output of a command executed using ExecuteCommand is not returned by ReadPacket. Many
+// servers do not send a CommandOutput at all and send Text packets instead, so ctx should generally have a
+// deadline. If ctx is done before the output is received, ExecuteCommand returns an error.
+func (conn *Conn) ExecuteCommand(ctx context.Context, commandLine string) (*packet.CommandOutput, error) {
+	id := uuid.New()
+	output := make(chan *packet.CommandOutput, 1)
+
+	conn.commandMu.Lock()
+	if conn.commands == nil {
+		conn.commands = make(map[uuid.UUID]chan *packet.CommandOutput)
+	}
+	conn.commands[id] = output
+	conn.commandMu.Unlock()
+	defer func() {
+		conn.commandMu.Lock()
+		delete(conn.commands, id)
+		conn.commandMu.Unlock()
+	}()
+
+	if err := conn.WritePacket(&packet.CommandRequest{
+		CommandLine:   commandLine,
+		CommandOrigin: protocol.CommandOrigin{Origin: protocol.CommandOriginPlayer, UUID: id},
+	}); err != nil {
+		return nil, err
+	}
+	select {
+	case <-conn.ctx.Done():
+		return nil, conn.closeErr("execute command")
+	case <-ctx.Done():
+		return nil, conn.wrap(ctx.Err(), "execute command")
+	case pk := <-output:
+		return pk, nil
+	}
+}
+
+// commandOutput passes the CommandOutput packet held in the packetData to the call of ExecuteCommand waiting
+// for it, if any. commandOutput returns true if the packet was passed, in which case it should not be read
+// by ReadPacket.
+func (conn *Conn) commandOutput(pkData *packetData) bool {
+	conn.commandMu.Lock()
+	defer conn.commandMu.Unlock()
+	if len(conn.commands) == 0 {
+		return false
+	}
+	// The packet decoded is kept in pkData, so that it is not decoded again if it turns out it should still be
+	// queued.
+	pks, err := pkData.decode(conn)
+	if err != nil || len(pks) == 0 {
+		return false
+	}
+	pk, ok := pks[0].(*packet.CommandOutput)
+	if !ok {
+		return false
+	}
+	output, ok := conn.commands[pk.CommandOrigin.UUID]
+	if !ok {
+		return false
+	}
+	delete(conn.commands, pk.CommandOrigin.UUID)
+	output <- pk
+	return true
+}
@@ -6,8 +6,8 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
-	"errors"
 	"fmt"
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
@@ -21,7 +21,9 @@ import (
 	"github.com/sandertv/gophertunnel/minecraft/text"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -54,30 +56,48 @@ type Conn struct {
 	ctx        context.Context
 	cancelFunc context.CancelCauseFunc
 
-	conn        net.Conn
-	log         *slog.Logger
-	authEnabled bool
+	conn               net.Conn
+	log                *slog.Logger
+	authEnabled        bool
+	encryptionDisabled bool
+	educationEdition   bool
 
 	proto         Protocol
 	acceptedProto []Protocol
 	pool          packet.Pool
+	customPackets packet.Pool
 	enc           *packet.Encoder
 	dec           *packet.Decoder
 	compression   packet.Compression
 	readerLimits  bool
+	// server is true if the Conn was accepted by a Listener, and false if it was obtained using a Dialer.
+	server bool
 
 	disconnectOnUnknownPacket bool
 	disconnectOnInvalidPacket bool
+	packetViolationWarnings   bool
+	strictLoginSequence       bool
 
 	identityData login.IdentityData
 	clientData   login.ClientData
+	// loginChain holds the raw connection request of the Login packet received from the client.
+	loginChain []byte
+	// verificationCache is the cache of verified login tokens used to parse the login request of a client.
+	// It is nil if no cache is used.
+	verificationCache *login.VerificationCache
 
 	gameData         GameData
 	gameDataReceived atomic.Bool
+	// startGamePacket is the StartGame packet received from the server. It is only set for client connections.
+	startGamePacket *packet.StartGame
 
 	// privateKey is the private key of this end of the connection. Each connection, regardless of which side
 	// the connection is on, server or client, has a unique private key generated.
 	privateKey *ecdsa.PrivateKey
+	// encryptionKey is the key used to encrypt the connection. It is only stored in builds with the
+	// gophertunnel_debugkey build tag, so that it may be returned by EncryptionKey.
+	encryptionKey []byte
+
 	// salt is a 16 byte long randomly generated byte slice which is only used if the Conn is a server sided
 	// connection. It is otherwise left unused.
 	salt []byte
@@ -85,6 +105,15 @@ type Conn struct {
 	// packets is a channel of byte slices containing serialised packets that are coming in from the other
 	// side of the connection.
 	packets chan *packetData
+	// overflowPolicy specifies what happens to packets received if maxQueuedPackets packets are already
+	// queued for reading.
+	overflowPolicy   OverflowPolicy
+	maxQueuedPackets int
+	// maxPacketsPerSecond is the maximum amount of packets queued within a single second. Packets received
+	// after this are dropped. If 0, the amount is not limited. rateWindow is the Unix time in seconds of the
+	// current second, in which rateCount packets were received. Both are only used on the read goroutine.
+	maxPacketsPerSecond   int
+	rateWindow, rateCount int64
 
 	deferredPacketMu sync.Mutex
 	// deferredPackets is a list of packets that were pushed back during the login sequence because they
@@ -92,19 +121,32 @@ type Conn struct {
 	// ReadPacket after being connected.
 	deferredPackets []*packetData
 	readDeadline    <-chan time.Time
+	// writeDeadline holds the time.Time set using SetWriteDeadline. If it is non-zero and passed, writing
+	// to and flushing the connection fails.
+	writeDeadline atomic.Value
 
 	sendMu sync.Mutex
 	// bufferedSend is a slice of byte slices containing packets that are 'written'. They are buffered until
 	// they are sent each 20th of a second.
 	bufferedSend [][]byte
-	hdr          *packet.Header
+	// sendArena holds the data of all packets in bufferedSend, so that buffering a packet does not need an
+	// allocation of its own. It is reused after every flush.
+	sendArena []byte
+	// bufferedBytes is the total size of all packets in bufferedSend. Once it would exceed
+	// maxBufferedBytes, writing to the connection fails until it is flushed.
+	bufferedBytes, maxBufferedBytes int
+	hdr                             *packet.Header
+
+	stats connStats
 
 	// readyToLogin is a bool indicating if the connection is ready to login. This is used to ensure that the client
 	// has received the relevant network settings before the login sequence starts.
 	readyToLogin bool
 	// loggedIn is a bool indicating if the connection was logged in. It is set to true after the entire login
-	// sequence is completed.
-	loggedIn bool
+	// sequence is completed. It is read from other goroutines through LoggedIn.
+	loggedIn atomic.Bool
+	// login is closed once loggedIn is set to true.
+	login chan struct{}
 	// spawn is a bool channel indicating if the connection is currently waiting for its spawning in
 	// the world: It is completing a sequence that will result in the spawning.
 	spawn           chan struct{}
@@ -124,15 +166,61 @@ type Conn struct {
 	// texturePacksRequired specifies if clients that join must accept the texture pack in order for them to
 	// be able to join the server. If they don't accept, they can only leave the server.
 	texturePacksRequired bool
-	packQueue            *resourcePackQueue
+	// packChunkSize is the size of the chunks in which resource packs are sent to the client.
+	packChunkSize uint64
+	packQueue     *resourcePackQueue
+	// sendingPacks is true while resource packs sent using SendResourcePacks are being downloaded. packsSent
+	// is closed once the client completed the sequence.
+	sendingPacks atomic.Bool
+	packsSent    chan struct{}
+	// packResults holds the outcome of each resource pack offered to a client, indexed by the UUID of the
+	// pack. It is only used for connections obtained through a Listener.
+	packResults map[string]ResourcePackResult
+	// packsRequested specifies if the client sent a list of packs to download in response to the last
+	// ResourcePacksInfo packet sent.
+	packsRequested bool
+	// packsInfoSent is the time at which the last ResourcePacksInfo packet was sent. packStatusFunc is an
+	// optional function passed by a Listener that is called when the client reaches a step in the resource
+	// pack sequence.
+	packsInfoSent  time.Time
+	packStatusFunc func(conn *Conn, response byte, d time.Duration)
 	// downloadResourcePack is an optional function passed to a Dial() call. If set, each resource pack received
 	// from the server will call this function to see if it should be downloaded or not.
 	downloadResourcePack func(id uuid.UUID, version string, currentPack, totalPacks int) bool
+	// resourcePackPolicy is an optional function passed to a Dial() call. If set, it is called for every
+	// resource pack received to decide if the pack should be downloaded.
+	resourcePackPolicy func(pack protocol.TexturePackInfo, required bool) bool
 	// ignoredResourcePacks is a slice of resource packs that are not being downloaded due to the downloadResourcePack
 	// func returning false for the specific pack.
 	ignoredResourcePacks []exemptedResourcePack
 
 	cacheEnabled bool
+	// respondToLatency specifies if NetworkStackLatency packets that need a response are responded to
+	// automatically.
+	respondToLatency bool
+	// pings holds the times at which the NetworkStackLatency packets sent to measure the round trip time were
+	// sent, indexed by their timestamp. It is nil if the round trip time is not measured. rtt and rttAverage
+	// hold the latest and moving average round trip time in nanoseconds.
+	pingMu          sync.Mutex
+	pings           map[int64]time.Time
+	rtt, rttAverage atomic.Int64
+
+	// network and address are the ID of the network and the address that a Conn obtained using a Dialer was
+	// dialed with. handleTransfers specifies if Transfer packets close the Conn with a TransferError, and
+	// transfers is the amount of transfers followed using Dialer.FollowTransfer to arrive at the Conn.
+	network, address string
+	handleTransfers  bool
+	transfers        int
+
+	// commands holds channels of the commands executed using ExecuteCommand that are awaiting their output,
+	// indexed by the UUID of the CommandOrigin of the request.
+	commandMu sync.Mutex
+	commands  map[uuid.UUID]chan *packet.CommandOutput
+
+	// subClients holds a channel for every split screen player, to which packets meant for that player are
+	// passed once SubClient is first called.
+	subClientOnce sync.Once
+	subClients    [4]chan packet.Packet
 
 	// packetFunc is an optional function passed to a Dial() call. If set, each packet read from and written
 	// to this connection will call this function.
@@ -140,7 +228,12 @@ type Conn struct {
 
 	shieldID atomic.Int32
 
+	// lastReceive is the time in Unix nanoseconds at which the last packet was received from the connection.
+	lastReceive atomic.Int64
+
 	additional chan packet.Packet
+	// additionalHeader is the packet.Header of the packets in additional.
+	additionalHeader packet.Header
 }
 
 // newConn creates a new Minecraft connection for the net.Conn passed, reading and writing compressed
@@ -155,12 +248,16 @@ func newConn(netConn net.Conn, key *ecdsa.PrivateKey, log *slog.Logger, proto Pr
 		packets:      make(chan *packetData, 8),
 		additional:   make(chan packet.Packet, 16),
 		spawn:        make(chan struct{}),
+		login:        make(chan struct{}),
 		conn:         netConn,
 		privateKey:   key,
 		log:          log.With("raddr", netConn.RemoteAddr().String()),
 		hdr:          &packet.Header{},
 		proto:        proto,
 		readerLimits: limits,
+
+		maxBufferedBytes: defaultMaxBufferedBytes,
+		packChunkSize:    defaultPackChunkSize,
 	}
 
 	if c, ok := netConn.(interface{ Context() context.Context }); ok {
@@ -206,6 +303,75 @@ func (conn *Conn) ClientData() login.ClientData {
 	return conn.clientData
 }
 
+// LoginChain returns the raw connection request that the client sent in its Login packet, holding the signed
+// login chain followed by the client data token. It is only set for a Conn obtained using a Listener, once
+// the login request was verified.
+// A proxy may forward the connection request to a backend server to connect on behalf of the client without
+// authenticating again. Note that the chain is bound to the key of the client rather than that of the
+// proxy, so the backend server must trust the verification of the proxy and cannot enable encryption
+// with the proxy using it.
+func (conn *Conn) LoginChain() []byte {
+	return conn.loginChain
+}
+
+// Err returns the error that caused the connection to be closed, such as an error decoding packets sent by
+// the other end, a DisconnectError if the other end disconnected using a packet.Disconnect, a
+// LoginFailedError if the login failed with a packet.PlayStatus, or ErrIdleTimeout. If the connection is
+// still open, or if it was closed using Close, Err returns nil. DisconnectReason returns the DisconnectReason
+// matching the error.
+func (conn *Conn) Err() error {
+	select {
+	case <-conn.ctx.Done():
+	default:
+		return nil
+	}
+	if cause := context.Cause(conn.ctx); cause != net.ErrClosed {
+		return cause
+	}
+	return nil
+}
+
+// LoggedIn returns true if the login sequence of the connection was completed. For a Conn obtained using a
+// Listener, this is the case once the client has finished downloading resource packs. For a Conn obtained
+// using Dial, it is the case once the client has spawned. LoggedIn may be called from any goroutine.
+func (conn *Conn) LoggedIn() bool {
+	return conn.loggedIn.Load()
+}
+
+// WaitLogin waits until the login sequence of the connection is completed, which is when LoggedIn starts
+// returning true. An error is returned if the context passed is done or if the connection is closed before
+// the login sequence is completed. Note that a Conn returned by Listener.Accept is always logged in already.
+func (conn *Conn) WaitLogin(ctx context.Context) error {
+	select {
+	case <-conn.login:
+		return nil
+	case <-conn.ctx.Done():
+		return conn.closeErr("wait login")
+	case <-ctx.Done():
+		return conn.wrap(ctx.Err(), "wait login")
+	}
+}
+
+// setLoggedIn marks the login sequence of the connection as completed.
+func (conn *Conn) setLoggedIn() {
+	if conn.loggedIn.CompareAndSwap(false, true) {
+		conn.log.Debug("logged in", "username", conn.identityData.DisplayName)
+		close(conn.login)
+	}
+}
+
+// FailLogin fails the login of the client by sending a packet.PlayStatus with the status passed, which should
+// be one of the packet.PlayStatusLoginFailed constants, after which the connection is flushed and closed.
+// The client shows a message to the player matching the status. FailLogin should only be called for a Conn
+// obtained using a Listener. It returns an error if the packet could not be written or flushed.
+func (conn *Conn) FailLogin(status int32) error {
+	if err := conn.WritePacket(&packet.PlayStatus{Status: status}); err != nil {
+		return err
+	}
+	// close flushes the packet before closing the underlying connection.
+	return conn.close(loginFailed(status))
+}
+
 // Authenticated returns true if the connection was authenticated through XBOX Live services.
 func (conn *Conn) Authenticated() bool {
 	return conn.IdentityData().XUID != ""
@@ -218,6 +384,14 @@ func (conn *Conn) GameData() GameData {
 	return conn.gameData
 }
 
+// StartGamePacket returns the packet.StartGame received from the server during the login sequence. GameData
+// holds the fields of the packet that are most commonly needed, and StartGamePacket may be used to access
+// the rest of its fields. It returns nil for a Conn obtained using a Listener. The packet returned must not
+// be modified.
+func (conn *Conn) StartGamePacket() *packet.StartGame {
+	return conn.startGamePacket
+}
+
 // Proto returns the protocol of the connection.
 func (conn *Conn) Proto() Protocol {
 	return conn.proto
@@ -319,11 +493,35 @@ func (conn *Conn) DoSpawnContext(ctx context.Context) error {
 // WritePacket encodes the packet passed and writes it to the Conn. The encoded data is buffered until the
 // next 20th of a second, after which the data is flushed and sent over the connection.
 func (conn *Conn) WritePacket(pk packet.Packet) error {
+	return conn.writePackets([]packet.Packet{pk}, 0, 0, "write packet")
+}
+
+// WritePacketTo encodes the packet passed and writes it to the Conn like WritePacket, but with the sender
+// and target sub client IDs passed in its header. These IDs identify the split screen player that sends or
+// should receive the packet and must be between 0 and 3, where 0 is the primary player.
+func (conn *Conn) WritePacketTo(pk packet.Packet, senderSubClient, targetSubClient byte) error {
+	return conn.writePackets([]packet.Packet{pk}, senderSubClient, targetSubClient, "write packet")
+}
+
+// WritePackets encodes all packets passed and writes them to the Conn in the order passed, like calling
+// WritePacket for each of them. The packets are written while holding the lock of the Conn only once, so
+// writing many packets at once using WritePackets is cheaper than calling WritePacket for each. If encoding
+// or buffering one of the packets fails, the packets before it are still written.
+func (conn *Conn) WritePackets(pks ...packet.Packet) error {
+	return conn.writePackets(pks, 0, 0, "write packets")
+}
+
+// writePackets encodes the packets passed with a header holding the sub client IDs passed and writes them
+// to the Conn. Errors returned are wrapped using the operation op.
+func (conn *Conn) writePackets(pks []packet.Packet, senderSubClient, targetSubClient byte, op string) error {
 	select {
 	case <-conn.ctx.Done():
-		return conn.closeErr("write packet")
+		return conn.closeErr(op)
 	default:
 	}
+	if conn.writeDeadlineExceeded() {
+		return conn.wrap(context.DeadlineExceeded, op)
+	}
 	conn.sendMu.Lock()
 	defer conn.sendMu.Unlock()
 
@@ -334,17 +532,25 @@ func (conn *Conn) WritePacket(pk packet.Packet) error {
 		internal.BufferPool.Put(buf)
 	}()
 
-	conn.hdr.PacketID = pk.ID()
-	_ = conn.hdr.Write(buf)
-	l := buf.Len()
+	for _, pk := range pks {
+		buf.Reset()
+		conn.hdr.PacketID = pk.ID()
+		conn.hdr.SenderSubClient, conn.hdr.TargetSubClient = senderSubClient, targetSubClient
+		if err := conn.hdr.Write(buf); err != nil {
+			return conn.wrap(err, op)
+		}
+		l := buf.Len()
 
-	for _, converted := range conn.proto.ConvertFromLatest(pk, conn) {
-		converted.Marshal(conn.proto.NewWriter(buf, conn.shieldID.Load()))
+		for _, converted := range conn.proto.ConvertFromLatest(pk, conn) {
+			converted.Marshal(conn.proto.NewWriter(buf, conn.shieldID.Load()))
 
-		if conn.packetFunc != nil {
-			conn.packetFunc(*conn.hdr, buf.Bytes()[l:], conn.LocalAddr(), conn.RemoteAddr())
+			if conn.packetFunc != nil {
+				conn.packetFunc(*conn.hdr, buf.Bytes()[l:], conn.LocalAddr(), conn.RemoteAddr())
+			}
+			if err := conn.buffer(buf.Bytes()); err != nil {
+				return conn.wrap(err, op)
+			}
 		}
-		conn.bufferedSend = append(conn.bufferedSend, append([]byte(nil), buf.Bytes()...))
 	}
 	return nil
 }
@@ -355,63 +561,216 @@ func (conn *Conn) WritePacket(pk packet.Packet) error {
 //
 // If the packet read was not implemented, a *packet.Unknown is returned, containing the raw payload of the
 // packet read.
+//
+// Every packet returned is newly allocated and does not share memory with buffers used by the Conn, so it
+// may safely be kept or passed to other goroutines after ReadPacket returns.
 func (conn *Conn) ReadPacket() (pk packet.Packet, err error) {
+	pk, _, err = conn.ReadPacketHeader()
+	return pk, err
+}
+
+// ReadPacketHeader reads a packet from the Conn like ReadPacket, but also returns the packet.Header that the
+// packet was sent with. The SenderSubClient and TargetSubClient of the header identify the split screen
+// player that sent or should receive the packet, which is 0 for the primary player.
+func (conn *Conn) ReadPacketHeader() (packet.Packet, packet.Header, error) {
 	if len(conn.additional) > 0 {
-		return <-conn.additional, nil
+		return <-conn.additional, conn.additionalHeader, nil
 	}
 	if data, ok := conn.takeDeferredPacket(); ok {
-		pk, err := data.decode(conn)
-		if err != nil {
-			conn.log.Error("read packet: " + err.Error())
-			return conn.ReadPacket()
-		}
-		if len(pk) == 0 {
-			return conn.ReadPacket()
-		}
-		for _, additional := range pk[1:] {
-			conn.additional <- additional
-		}
-		return pk[0], nil
+		return conn.readData(data)
 	}
 
 	select {
 	case <-conn.ctx.Done():
-		return nil, conn.closeErr("read packet")
+		return nil, packet.Header{}, conn.closeErr("read packet")
 	case <-conn.readDeadline:
-		return nil, conn.wrap(context.DeadlineExceeded, "read packet")
+		return nil, packet.Header{}, conn.wrap(context.DeadlineExceeded, "read packet")
 	case data := <-conn.packets:
-		pk, err := data.decode(conn)
-		if err != nil {
-			conn.log.Error("read packet: " + err.Error())
-			return conn.ReadPacket()
-		}
-		if len(pk) == 0 {
-			return conn.ReadPacket()
-		}
-		for _, additional := range pk[1:] {
-			conn.additional <- additional
-		}
-		return pk[0], nil
+		return conn.readData(data)
 	}
 }
 
+// readData decodes the packetData passed and returns the first packet it holds, along with its header. Any
+// other packets are returned by subsequent reads. If the data could not be decoded, the next packet is read
+// instead.
+func (conn *Conn) readData(data *packetData) (packet.Packet, packet.Header, error) {
+	pk, err := data.decode(conn)
+	if err != nil {
+		conn.log.Error("read packet: " + err.Error())
+		return conn.ReadPacketHeader()
+	}
+	if len(pk) == 0 {
+		return conn.ReadPacketHeader()
+	}
+	conn.additionalHeader = *data.h
+	for _, additional := range pk[1:] {
+		conn.additional <- additional
+	}
+	return pk[0], *data.h, nil
+}
+
 // ResourcePacks returns a slice of all resource packs the connection holds. For a Conn obtained using a
 // Listener, this holds all resource packs set to the Listener. For a Conn obtained using Dial, the resource
 // packs include all packs sent by the server connected to.
 func (conn *Conn) ResourcePacks() []*resource.Pack {
-	return conn.resourcePacks
+	return slices.Clone(conn.packs())
+}
+
+// packs returns the resource packs of the connection. The slice returned must not be modified, as it may be
+// shared with the Conn. The packs are replaced by SendResourcePacks and extended by packs downloaded from a
+// server, so conn.resourcePacks must only be accessed while holding packMu.
+func (conn *Conn) packs() []*resource.Pack {
+	conn.packMu.Lock()
+	defer conn.packMu.Unlock()
+	return conn.resourcePacks[:len(conn.resourcePacks):len(conn.resourcePacks)]
+}
+
+// SendResourcePacks sends the resource packs passed to a client that has already spawned, restarting the
+// resource pack sequence of the login: The client downloads the packs it does not yet have, after which the
+// packs are applied. The packs passed replace the resource packs of the Conn. SendResourcePacks blocks until
+// the client completed the sequence, the connection is closed or the context passed is cancelled. While the
+// sequence is in progress, packets received are still returned by ReadPacket.
+// SendResourcePacks may only be called for a Conn obtained using a minecraft.Listener. It returns an error if
+// the Conn was obtained otherwise, if the client has not yet spawned or if another call to SendResourcePacks
+// is still in progress.
+func (conn *Conn) SendResourcePacks(ctx context.Context, packs ...*resource.Pack) error {
+	if !conn.server {
+		return conn.wrap(fmt.Errorf("not a Listener connection"), "send resource packs")
+	}
+	select {
+	case <-conn.spawn:
+	default:
+		return conn.wrap(fmt.Errorf("client has not yet spawned"), "send resource packs")
+	}
+	if !conn.sendingPacks.CompareAndSwap(false, true) {
+		return conn.wrap(fmt.Errorf("resource packs are already being sent"), "send resource packs")
+	}
+	sent := make(chan struct{})
+	conn.packMu.Lock()
+	conn.resourcePacks = slices.Clone(packs)
+	conn.packsSent = sent
+	conn.packMu.Unlock()
+
+	conn.expect(packet.IDResourcePackClientResponse)
+	err := conn.sendResourcePacksInfo()
+	if err == nil {
+		err = conn.Flush()
+	}
+	if err != nil {
+		conn.stopSendingPacks()
+		return conn.wrap(err, "send resource packs")
+	}
+	select {
+	case <-conn.ctx.Done():
+		return conn.closeErr("send resource packs")
+	case <-ctx.Done():
+		// Stop handling the sequence, so that packets received are no longer held back.
+		conn.stopSendingPacks()
+		return conn.wrap(ctx.Err(), "send resource packs")
+	case <-sent:
+		return nil
+	}
+}
+
+// stopSendingPacks stops the resource pack sequence started by SendResourcePacks before it completed. The
+// packets expected during the sequence are no longer expected, so that a late response of the client is
+// returned by ReadPacket rather than handled.
+func (conn *Conn) stopSendingPacks() {
+	conn.packMu.Lock()
+	conn.packsSent = nil
+	conn.packMu.Unlock()
+	conn.expect()
+	conn.sendingPacks.Store(false)
+}
+
+// ReadBatch reads all packets of the next batch received from the Conn. Unlike ReadPacket, which returns the
+// packets of a batch one by one, ReadBatch preserves the grouping of packets sent together by the other end
+// of the connection. If a read deadline is set, an error is returned if the deadline is reached before the
+// full batch is received. ReadBatch must not be called on multiple goroutines simultaneously, nor at the same
+// time as ReadPacket.
+//
+// Packets that could not be decoded are logged and left out of the batch returned. Packets that were deferred
+// during the login sequence are each returned as a batch of their own.
+func (conn *Conn) ReadBatch() ([]packet.Packet, error) {
+	var pks []packet.Packet
+	for len(conn.additional) > 0 {
+		pks = append(pks, <-conn.additional)
+	}
+	for {
+		data, ok := conn.takeDeferredPacket()
+		if !ok {
+			select {
+			case <-conn.ctx.Done():
+				return nil, conn.closeErr("read batch")
+			case <-conn.readDeadline:
+				return nil, conn.wrap(context.DeadlineExceeded, "read batch")
+			case data = <-conn.packets:
+			}
+		}
+		decoded, err := data.decode(conn)
+		if err != nil {
+			conn.log.Error("read batch: " + err.Error())
+		} else {
+			pks = append(pks, decoded...)
+		}
+		if data.batchEnd && len(pks) != 0 {
+			return pks, nil
+		}
+	}
+}
+
+// ResourcePackResults returns the outcome of each resource pack offered to the client, indexed by the UUID of
+// the pack. It is only filled out for a Conn obtained using a Listener. ResourcePackResults should generally be
+// called after the Conn was accepted, at which point the client has finished responding to the packs.
+func (conn *Conn) ResourcePackResults() map[string]ResourcePackResult {
+	conn.packMu.Lock()
+	defer conn.packMu.Unlock()
+	return maps.Clone(conn.packResults)
 }
 
 // Write writes a slice of serialised packet data to the Conn. The data is buffered until the next 20th of a
-// tick, after which it is flushed to the connection. Write returns the amount of bytes written n.
+// tick, after which it is flushed to the connection. Write returns the amount of bytes written n. The data is
+// copied, so b may be reused once Write returns.
 func (conn *Conn) Write(b []byte) (n int, err error) {
+	if conn.writeDeadlineExceeded() {
+		return 0, conn.wrap(context.DeadlineExceeded, "write")
+	}
 	conn.sendMu.Lock()
 	defer conn.sendMu.Unlock()
 
-	conn.bufferedSend = append(conn.bufferedSend, b)
+	if err := conn.buffer(b); err != nil {
+		return 0, conn.wrap(err, "write")
+	}
 	return len(b), nil
 }
 
+// defaultMaxBufferedBytes is the maximum total size of packets buffered by a Conn if no maximum is set.
+const defaultMaxBufferedBytes = 64 * 1024 * 1024
+
+// maxRetainedArena is the maximum capacity of the sendArena of a Conn that is kept after a flush. Larger
+// arenas, which are only needed after bursts of packets, are released so that their memory may be freed.
+const maxRetainedArena = 1024 * 1024
+
+// buffer adds a copy of a serialised packet to the packets buffered until the next flush. An error is
+// returned if buffering the packet would cause the total size of buffered packets to exceed the maximum.
+// buffer must only be called while holding sendMu.
+func (conn *Conn) buffer(b []byte) error {
+	if conn.bufferedBytes+len(b) > conn.maxBufferedBytes {
+		return fmt.Errorf("send buffer full: %v bytes buffered, maximum is %v", conn.bufferedBytes, conn.maxBufferedBytes)
+	}
+	if cap(conn.sendArena)-len(conn.sendArena) < len(b) {
+		// The packets already buffered keep referencing the old arena, so it cannot simply be grown using
+		// append.
+		conn.sendArena = make([]byte, 0, max(len(b), cap(conn.sendArena)*2, 4096))
+	}
+	start := len(conn.sendArena)
+	conn.sendArena = append(conn.sendArena, b...)
+	conn.bufferedSend = append(conn.bufferedSend, conn.sendArena[start:len(conn.sendArena):len(conn.sendArena)])
+	conn.bufferedBytes += len(b)
+	conn.stats.bytesBuffered.Store(uint64(conn.bufferedBytes))
+	return nil
+}
+
 // ReadBytes reads a packet from the connection without decoding it directly.
 // For direct reading, consider using ReadPacket() which decodes the packet.
 func (conn *Conn) ReadBytes() ([]byte, error) {
@@ -453,34 +812,133 @@ func (conn *Conn) Read(b []byte) (n int, err error) {
 
 // Flush flushes the packets currently buffered by the connections to the underlying net.Conn, so that they
 // are directly sent.
+// If a write deadline set using SetWriteDeadline has passed, Flush returns an error and the buffered packets
+// are kept. When this happens in the automatic flushing of the Conn, the Conn is closed.
 func (conn *Conn) Flush() error {
+	_, err := conn.FlushN()
+	return err
+}
+
+// FlushContext flushes the packets currently buffered like Flush, but returns an error if the context passed
+// is done before the packets could be written to the underlying net.Conn. For the duration of the call, the
+// deadline of the context is set as write deadline of the underlying net.Conn, and the write deadline is
+// moved to the present if the context is cancelled, so that a write blocking on a stalled transport returns.
+// Afterwards, the write deadline set using SetWriteDeadline is restored. Transports that do not support
+// write deadlines, like RakNet, never block on writes, so for these the context only has an effect if it is
+// done before FlushContext is called.
+func (conn *Conn) FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return conn.wrap(err, "flush")
+	}
+	deadline, _ := conn.writeDeadline.Load().(time.Time)
+	if t, ok := ctx.Deadline(); ok && (deadline.IsZero() || t.Before(deadline)) {
+		_ = conn.conn.SetWriteDeadline(t)
+	}
+	cancelled := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.conn.SetWriteDeadline(time.Now())
+		close(cancelled)
+	})
+	_, err := conn.FlushN()
+	if !stop() {
+		// The context was done during the flush: Wait for the write deadline to be set so that we don't
+		// restore it before it is changed.
+		<-cancelled
+	}
+	_ = conn.conn.SetWriteDeadline(deadline)
+
+	if err != nil && ctx.Err() != nil {
+		return conn.wrap(ctx.Err(), "flush")
+	}
+	return err
+}
+
+// FlushN flushes the packets currently buffered like Flush, but also returns the amount of bytes sent over
+// the underlying net.Conn. This is the size of the batch sent after compression and encryption, which is 0
+// if no packets were buffered.
+func (conn *Conn) FlushN() (int, error) {
 	select {
 	case <-conn.ctx.Done():
-		return conn.closeErr("flush")
+		return 0, conn.closeErr("flush")
 	default:
 	}
 	conn.sendMu.Lock()
 	defer conn.sendMu.Unlock()
-
-	if len(conn.bufferedSend) > 0 {
-		if err := conn.enc.Encode(conn.bufferedSend); err != nil && !errors.Is(err, net.ErrClosed) {
-			// Should never happen.
-			panic(fmt.Errorf("error encoding packet batch: %w", err))
+	return conn.flush()
+}
+
+// flush encodes all packets currently buffered into one or more batches and writes them to the underlying
+// net.Conn, returning the amount of bytes written. If writing a batch fails, the packets of the batches
+// written before it are removed from the buffer, so that they are not sent again by the next flush, and the
+// error is returned. If the failed batch was partly written or encrypted, the peer cannot decode any batch
+// following it, so the Conn is closed instead. flush must only be called while holding sendMu.
+func (conn *Conn) flush() (int, error) {
+	if len(conn.bufferedSend) == 0 {
+		return 0, nil
+	}
+	if conn.writeDeadlineExceeded() {
+		return 0, conn.wrap(context.DeadlineExceeded, "flush")
+	}
+	var n, sent int
+	// The packets are split into multiple batches if a maximum batch size is set. The batches are written in
+	// order, so the order of the packets is preserved.
+	for _, batch := range conn.enc.Split(conn.bufferedSend) {
+		batchN, err := conn.enc.EncodeN(batch)
+		n += batchN
+		conn.stats.wireBytesWritten.Add(uint64(batchN))
+		if err != nil {
+			err = conn.wrap(err, "flush")
+			if batchN > 0 || conn.enc.EncryptionEnabled() {
+				// Encrypting the batch advanced the encryption state, or part of the batch was written, so
+				// the stream can no longer be decoded by the peer. Sending the packets left is pointless.
+				conn.dropSent(len(conn.bufferedSend))
+				// close flushes while holding sendMu, which is currently held, so it is closed in a new goroutine.
+				go conn.close(err)
+				return n, err
+			}
+			conn.dropSent(sent)
+			return n, err
 		}
-		// First manually clear out conn.bufferedSend so that re-using the slice after resetting its length to
-		// 0 doesn't result in an 'invisible' memory leak.
-		for i := range conn.bufferedSend {
-			conn.bufferedSend[i] = nil
+		conn.stats.batchesWritten.Add(1)
+		conn.stats.packetsWritten.Add(uint64(len(batch)))
+		for _, b := range batch {
+			conn.stats.bytesWritten.Add(uint64(len(b)))
 		}
-		// Slice the conn.bufferedSend to a length of 0 so we don't have to re-allocate space in this slice
-		// every time.
-		conn.bufferedSend = conn.bufferedSend[:0]
+		sent += len(batch)
+	}
+	// First manually clear out conn.bufferedSend so that re-using the slice after resetting its length to 0
+	// doesn't result in an 'invisible' memory leak.
+	clear(conn.bufferedSend)
+	// Slice the conn.bufferedSend to a length of 0 so we don't have to re-allocate space in this slice every
+	// time.
+	conn.bufferedSend = conn.bufferedSend[:0]
+	if cap(conn.sendArena) > maxRetainedArena {
+		conn.sendArena = nil
+	} else {
+		conn.sendArena = conn.sendArena[:0]
 	}
-	return nil
+	conn.bufferedBytes = 0
+	conn.stats.bytesBuffered.Store(0)
+	return n, nil
 }
 
-// Close closes the Conn and its underlying connection. Before closing, it also calls Flush() so that any
-// packets currently pending are sent out.
+// dropSent removes the first count packets from conn.bufferedSend after they were sent by a flush that failed
+// to send the rest. The sendArena is left intact, as the packets that remain still point into it. dropSent
+// must only be called while holding sendMu.
+func (conn *Conn) dropSent(count int) {
+	for _, b := range conn.bufferedSend[:count] {
+		conn.bufferedBytes -= len(b)
+	}
+	remaining := copy(conn.bufferedSend, conn.bufferedSend[count:])
+	clear(conn.bufferedSend[remaining:])
+	conn.bufferedSend = conn.bufferedSend[:remaining]
+	conn.stats.bytesBuffered.Store(uint64(conn.bufferedBytes))
+}
+
+// Close closes the Conn and its underlying connection. Before closing, it also flushes the Conn so that all
+// packets currently pending are sent out, even if they need multiple batches. If the underlying net.Conn
+// supports write deadlines, this flush gives up after 5 seconds, so that Close does not block on a peer that
+// stopped reading.
 func (conn *Conn) Close() error {
 	return conn.close(net.ErrClosed)
 }
@@ -498,7 +956,10 @@ func (conn *Conn) RemoteAddr() net.Addr {
 // SetDeadline sets the read and write deadline of the connection. It is equivalent to calling SetReadDeadline
 // and SetWriteDeadline at the same time.
 func (conn *Conn) SetDeadline(t time.Time) error {
-	return conn.SetReadDeadline(t)
+	if err := conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return conn.SetWriteDeadline(t)
 }
 
 // SetReadDeadline sets the read deadline of the Conn to the time passed. The time must be after time.Now().
@@ -515,20 +976,38 @@ func (conn *Conn) SetReadDeadline(t time.Time) error {
 	return nil
 }
 
-// SetWriteDeadline is a stub function to implement net.Conn. It has no functionality.
-func (conn *Conn) SetWriteDeadline(time.Time) error {
+// SetWriteDeadline sets the write deadline of the Conn to the time passed. Once the deadline has passed, calls
+// to Write, WritePacket and Flush return an error. The deadline is also set to the underlying net.Conn if it
+// supports write deadlines, so that a write blocking on a stalled transport returns once the deadline passes.
+// Passing an empty time.Time to the method (time.Time{}) results in the write deadline being cleared.
+func (conn *Conn) SetWriteDeadline(t time.Time) error {
+	conn.writeDeadline.Store(t)
+	// Not all transports support write deadlines (RakNet does not), so the error is not returned.
+	_ = conn.conn.SetWriteDeadline(t)
 	return nil
 }
 
+// writeDeadlineExceeded checks if the write deadline set using SetWriteDeadline has passed.
+func (conn *Conn) writeDeadlineExceeded() bool {
+	t, _ := conn.writeDeadline.Load().(time.Time)
+	return !t.IsZero() && time.Now().After(t)
+}
+
 // Latency returns a rolling average of latency between the sending and the receiving end of the connection.
-// The latency returned is updated continuously and is half the round trip time (RTT).
+// The latency returned is updated continuously and is half the round trip time (RTT). If
+// LatencyPingInterval was set in the Dialer or ListenConfig, the latency is half the average returned by
+// RoundTripTime once the first measurement was made. Otherwise, the latency is taken from the underlying
+// net.Conn if it has a Latency method, as RakNet connections do. Latency returns 0 if neither is available.
 func (conn *Conn) Latency() time.Duration {
+	if _, average, ok := conn.RoundTripTime(); ok {
+		return average / 2
+	}
 	if c, ok := conn.conn.(interface {
 		Latency() time.Duration
 	}); ok {
 		return c.Latency()
 	}
-	panic(fmt.Sprintf("connection type %T has no Latency() time.Duration method", conn.conn))
+	return 0
 }
 
 // ClientCacheEnabled checks if the connection has the client blob cache enabled. If true, the server may send
@@ -551,6 +1030,21 @@ func (conn *Conn) Context() context.Context {
 	return conn.ctx
 }
 
+// packetPool returns the packet.Pool of the Protocol of the connection, extended with the custom packets
+// registered for the connection.
+func (conn *Conn) packetPool(listener bool) packet.Pool {
+	pool := conn.proto.Packets(listener)
+	if len(conn.customPackets) == 0 {
+		return pool
+	}
+	// The Protocol might return the same pool for every call, so we clone it before registering packets.
+	pool = maps.Clone(pool)
+	for id, pk := range conn.customPackets {
+		pool.Register(id, pk)
+	}
+	return pool
+}
+
 // takeDeferredPacket locks the deferred packets lock and takes the next packet from the list of deferred
 // packets. If none was found, it returns false, and if one was found, the data and true is returned.
 func (conn *Conn) takeDeferredPacket() (*packetData, bool) {
@@ -577,36 +1071,45 @@ func (conn *Conn) deferPacket(pk *packetData) {
 }
 
 // receive receives an incoming serialised packet from the underlying connection. If the connection is not yet
-// logged in, the packet is immediately handled.
-func (conn *Conn) receive(data []byte) error {
+// logged in, the packet is immediately handled. batchEnd specifies if the packet was the last packet of the
+// batch it was received in.
+func (conn *Conn) receive(data []byte, batchEnd bool) error {
+	conn.stats.packetsRead.Add(1)
+	conn.stats.bytesRead.Add(uint64(len(data)))
+	conn.lastReceive.Store(time.Now().UnixNano())
+
 	pkData, err := parseData(data, conn)
 	if err != nil {
 		return err
 	}
+	pkData.batchEnd = batchEnd
 	if pkData.h.PacketID == packet.IDDisconnect {
 		// We always handle disconnect packets and close the connection if one comes in.
 		pks, err := pkData.decode(conn)
 		if err != nil {
 			return err
 		}
-		_ = conn.close(conn.closeErr(pks[0].(*packet.Disconnect).Message))
+		// A custom packet may be registered with the ID of the Disconnect packet, in which case the packet is
+		// handled like any other packet.
+		if len(pks) != 0 {
+			if pk, ok := pks[0].(*packet.Disconnect); ok {
+				_ = conn.close(DisconnectError(pk.Message))
+				return nil
+			}
+		}
+	}
+	if pkData.h.PacketID == packet.IDTransfer && conn.handleTransfers && conn.transfer(pkData) {
 		return nil
 	}
-	if conn.loggedIn && !conn.waitingForSpawn.Load() {
-		select {
-		case <-conn.ctx.Done():
-		case previous := <-conn.packets:
-			// There was already a packet in this channel, so take it out and defer it so that it is read
-			// next.
-			conn.deferPacket(previous)
-		default:
-		}
-		select {
-		case <-conn.ctx.Done():
-		case conn.packets <- pkData:
-		}
+	if pkData.h.PacketID == packet.IDCommandOutput && conn.commandOutput(pkData) {
+		return nil
+	}
+	if pkData.h.PacketID == packet.IDNetworkStackLatency && conn.networkStackLatency(pkData) {
 		return nil
 	}
+	if conn.loggedIn.Load() && !conn.waitingForSpawn.Load() && !conn.sendingPacks.Load() {
+		return conn.queue(pkData)
+	}
 	return conn.handle(pkData)
 }
 
@@ -622,8 +1125,15 @@ func (conn *Conn) handle(pkData *packetData) error {
 			return conn.handleMultiple(pks)
 		}
 	}
+	if conn.strictLoginSequence && !conn.loggedIn.Load() {
+		conn.log.Debug("handle: unexpected packet during login", "ID", pkData.h.PacketID)
+		return fmt.Errorf("unexpected packet (ID=%v) during login, expected one of %v", pkData.h.PacketID, conn.expectedIDs.Load())
+	}
 	// This is not the packet we expected next in the login sequence. We push it back so that it may
-	// be handled by the user.
+	// be handled by the user. The other packets of its batch might have been handled already, so the packet
+	// is considered to be a batch of its own.
+	conn.log.Debug("handle: deferring unexpected packet during login", "ID", pkData.h.PacketID)
+	pkData.batchEnd = true
 	conn.deferPacket(pkData)
 	return nil
 }
@@ -697,7 +1207,7 @@ func (conn *Conn) handleRequestNetworkSettings(pk *packet.RequestNetworkSettings
 	for _, pro := range conn.acceptedProto {
 		if pro.ID() == pk.ClientProtocol {
 			conn.proto = pro
-			conn.pool = pro.Packets(true)
+			conn.pool = conn.packetPool(true)
 			found = true
 			break
 		}
@@ -708,7 +1218,7 @@ func (conn *Conn) handleRequestNetworkSettings(pk *packet.RequestNetworkSettings
 			// The server is outdated in this case, so we have to change the status we send.
 			status = packet.PlayStatusLoginFailedServer
 		}
-		_ = conn.WritePacket(&packet.PlayStatus{Status: status})
+		_ = conn.FailLogin(status)
 		return fmt.Errorf("incompatible protocol version: expected %v, got %v", protocol.CurrentProtocol, pk.ClientProtocol)
 	}
 
@@ -719,9 +1229,7 @@ func (conn *Conn) handleRequestNetworkSettings(pk *packet.RequestNetworkSettings
 	}); err != nil {
 		return fmt.Errorf("send NetworkSettings: %w", err)
 	}
-	_ = conn.Flush()
-	conn.enc.EnableCompression(conn.compression)
-	conn.dec.EnableCompression()
+	conn.enableCompression(conn.compression)
 	return nil
 }
 
@@ -731,12 +1239,25 @@ func (conn *Conn) handleNetworkSettings(pk *packet.NetworkSettings) error {
 	if !ok {
 		return fmt.Errorf("unknown compression algorithm %v", pk.CompressionAlgorithm)
 	}
-	conn.enc.EnableCompression(alg)
-	conn.dec.EnableCompression()
+	conn.enableCompression(alg)
 	conn.readyToLogin = true
 	return nil
 }
 
+// enableCompression enables compression using the algorithm passed for all packets written and read after the
+// call. Packets buffered before the call, such as the NetworkSettings packet, are first flushed without
+// compression. Because the flush and the switch both happen while holding sendMu, packets written
+// concurrently, or a flush by another goroutine, can never end up in a batch with the wrong compression.
+// enableCompression must be called from the goroutine reading packets, so that the Decoder switches exactly
+// after the batch holding the packet that caused the switch.
+func (conn *Conn) enableCompression(alg packet.Compression) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	_, _ = conn.flush()
+	conn.enc.EnableCompression(alg)
+	conn.dec.EnableCompression()
+}
+
 // handleLogin handles an incoming login packet. It verifies and decodes the login request found in the packet
 // and returns an error if it couldn't be done successfully.
 func (conn *Conn) handleLogin(pk *packet.Login) error {
@@ -746,16 +1267,30 @@ func (conn *Conn) handleLogin(pk *packet.Login) error {
 		err        error
 		authResult login.AuthResult
 	)
-	conn.identityData, conn.clientData, authResult, err = login.Parse(pk.ConnectionRequest)
+	conn.identityData, conn.clientData, authResult, err = conn.verificationCache.Parse(pk.ConnectionRequest)
 	if err != nil {
 		return fmt.Errorf("parse login request: %w", err)
 	}
+	conn.loginChain = pk.ConnectionRequest
 
 	// Make sure the player is logged in with XBOX Live when necessary.
 	if !authResult.XBOXLiveAuthenticated && conn.authEnabled {
 		_ = conn.WritePacket(&packet.Disconnect{Message: text.Colourf("<red>You must be logged in with XBOX Live to join.</red>")})
 		return fmt.Errorf("client was not authenticated to XBOX Live")
 	}
+	if edu := conn.clientData.EducationEdition(); edu != conn.educationEdition {
+		status := packet.PlayStatusLoginFailedEduVanilla
+		if conn.educationEdition {
+			status = packet.PlayStatusLoginFailedVanillaEdu
+		}
+		_ = conn.FailLogin(status)
+		return fmt.Errorf("incompatible edition: %v", loginFailed(status).Reason)
+	}
+	if conn.encryptionDisabled {
+		// Without encryption, the client does not need to respond to a handshake, so we can continue as if
+		// it had already done so.
+		return conn.handleClientToServerHandshake()
+	}
 	if err := conn.enableEncryption(authResult.PublicKey); err != nil {
 		return fmt.Errorf("enable encryption: %w", err)
 	}
@@ -769,8 +1304,23 @@ func (conn *Conn) handleClientToServerHandshake() error {
 	if err := conn.WritePacket(&packet.PlayStatus{Status: packet.PlayStatusLoginSuccess}); err != nil {
 		return fmt.Errorf("send PlayStatus (Status=LoginSuccess): %w", err)
 	}
+	return conn.sendResourcePacksInfo()
+}
+
+// sendResourcePacksInfo sends a ResourcePacksInfo packet holding all resource packs of the connection and
+// resets the ResourcePackResult of each of those packs.
+func (conn *Conn) sendResourcePacksInfo() error {
 	pk := &packet.ResourcePacksInfo{TexturePackRequired: conn.texturePacksRequired}
-	for _, pack := range conn.resourcePacks {
+	packs := conn.packs()
+	conn.packMu.Lock()
+	conn.packResults = make(map[string]ResourcePackResult, len(packs))
+	for _, pack := range packs {
+		conn.packResults[pack.UUID().String()] = ResourcePackPending
+	}
+	conn.packsRequested = false
+	conn.packsInfoSent = time.Now()
+	conn.packMu.Unlock()
+	for _, pack := range packs {
 		texturePack := protocol.TexturePackInfo{
 			UUID:        pack.UUID(),
 			Version:     pack.Version(),
@@ -821,14 +1371,17 @@ func (conn *Conn) handleServerToClientHandshake(pk *packet.ServerToClientHandsha
 	if err != nil {
 		return fmt.Errorf("decode ServerToClientHandshake salt: %w", err)
 	}
+	if err := validateSalt(salt); err != nil {
+		return fmt.Errorf("validate ServerToClientHandshake salt: %w", err)
+	}
 
-	x, _ := pub.Curve.ScalarMult(pub.X, pub.Y, conn.privateKey.D.Bytes())
-	// Make sure to pad the shared secret up to 96 bytes.
-	sharedSecret := append(bytes.Repeat([]byte{0}, 48-len(x.Bytes())), x.Bytes()...)
-
-	keyBytes := sha256.Sum256(append(salt, sharedSecret...))
+	keyBytes, err := conn.deriveKey(pub, salt)
+	if err != nil {
+		return err
+	}
 
 	// Finally we enable encryption for the enc and dec using the secret pubKey bytes we produced.
+	conn.storeEncryptionKey(keyBytes)
 	conn.enc.EnableEncryption(keyBytes)
 	conn.dec.EnableEncryption(keyBytes)
 
@@ -837,6 +1390,19 @@ func (conn *Conn) handleServerToClientHandshake(pk *packet.ServerToClientHandsha
 	return nil
 }
 
+// validateSalt checks if the salt sent by a server in the ServerToClientHandshake packet is 16 bytes long, like
+// the salt sent by vanilla servers, and if it is not trivial. A salt made up of a single repeated byte, such as
+// a salt of only zeroes, is rejected as it was not generated randomly.
+func validateSalt(salt []byte) error {
+	if len(salt) != 16 {
+		return fmt.Errorf("invalid salt length: got %v, expected 16", len(salt))
+	}
+	if bytes.Count(salt, salt[:1]) == len(salt) {
+		return fmt.Errorf("salt %x consists of a single repeated byte", salt)
+	}
+	return nil
+}
+
 // handleClientCacheStatus handles a ClientCacheStatus packet sent by the client. It specifies if the client
 // has support for the client blob cache.
 func (conn *Conn) handleClientCacheStatus(pk *packet.ClientCacheStatus) error {
@@ -864,11 +1430,24 @@ func (conn *Conn) handleResourcePacksInfo(pk *packet.ResourcePacksInfo) error {
 			conn.packQueue.packAmount--
 			continue
 		}
-		if conn.downloadResourcePack != nil && !conn.downloadResourcePack(uuid.MustParse(id), pack.Version, index, totalPacks) {
+		// Packs with a download URL are not sent over the connection and must be downloaded over HTTP, which
+		// is not supported, so these packs are always skipped.
+		accepted := pack.DownloadURL == "" && (conn.downloadResourcePack == nil || conn.downloadResourcePack(uuid.MustParse(id), pack.Version, index, totalPacks))
+		if accepted && conn.resourcePackPolicy != nil && !conn.resourcePackPolicy(pack, pk.TexturePackRequired) {
+			if pk.TexturePackRequired {
+				// The server requires all packs to be downloaded, so declining one means we cannot join.
+				_ = conn.WritePacket(&packet.ResourcePackClientResponse{Response: packet.PackResponseRefused})
+				return fmt.Errorf("texture pack (UUID=%v, version=%v) declined but required by server", pack.UUID, pack.Version)
+			}
+			accepted = false
+		}
+		if !accepted {
+			conn.packMu.Lock()
 			conn.ignoredResourcePacks = append(conn.ignoredResourcePacks, exemptedResourcePack{
 				uuid:    id,
 				version: pack.Version,
 			})
+			conn.packMu.Unlock()
 			conn.packQueue.packAmount--
 			continue
 		}
@@ -910,12 +1489,12 @@ func (conn *Conn) handleResourcePackStack(pk *packet.ResourcePackStack) error {
 				pk.BehaviourPacks = append(pk.BehaviourPacks[:i], pk.BehaviourPacks[i+1:]...)
 			}
 		}
-		if !conn.hasPack(pack.UUID, pack.Version, false) {
+		if !conn.hasPack(pack.UUID, pack.Version) {
 			return fmt.Errorf("texture pack (UUID=%v, version=%v) not downloaded", pack.UUID, pack.Version)
 		}
 	}
 	for _, pack := range pk.BehaviourPacks {
-		if !conn.hasPack(pack.UUID, pack.Version, true) {
+		if !conn.hasPack(pack.UUID, pack.Version) {
 			return fmt.Errorf("behaviour pack (UUID=%v, version=%v) not downloaded", pack.UUID, pack.Version)
 		}
 	}
@@ -924,9 +1503,9 @@ func (conn *Conn) handleResourcePackStack(pk *packet.ResourcePackStack) error {
 	return nil
 }
 
-// hasPack checks if the connection has a resource pack downloaded with the UUID and version passed, provided
-// the pack either has or does not have behaviours in it.
-func (conn *Conn) hasPack(uuid string, version string, hasBehaviours bool) bool {
+// hasPack checks if the connection has a resource pack downloaded with the UUID and version passed. Whether
+// the pack has behaviours is not checked, as servers may place a pack in either list of the stack.
+func (conn *Conn) hasPack(uuid string, version string) bool {
 	for _, exempted := range exemptedPacks {
 		if exempted.uuid == uuid && exempted.version == version {
 			// The server may send this resource pack on the stack without sending it in the info, as the client
@@ -935,50 +1514,85 @@ func (conn *Conn) hasPack(uuid string, version string, hasBehaviours bool) bool
 		}
 	}
 	conn.packMu.Lock()
-	defer conn.packMu.Unlock()
-
-	for _, ignored := range conn.ignoredResourcePacks {
-		if ignored.uuid == uuid && ignored.version == version {
-			return true
-		}
+	ignored := slices.ContainsFunc(conn.ignoredResourcePacks, func(pack exemptedResourcePack) bool {
+		return pack.uuid == uuid && pack.version == version
+	})
+	conn.packMu.Unlock()
+	if ignored {
+		return true
 	}
-	for _, pack := range conn.resourcePacks {
-		if pack.UUID().String() == uuid && pack.Version() == version && pack.HasBehaviours() == hasBehaviours {
+	for _, pack := range conn.packs() {
+		if pack.UUID().String() == uuid && pack.Version() == version {
 			return true
 		}
 	}
 	return false
 }
 
-// packChunkSize is the size of a single chunk of data from a resource pack: 512 kB or 0.5 MB
-const packChunkSize = 1024 * 128
-
 // handleResourcePackClientResponse handles an incoming resource pack client response packet. The packet is
 // handled differently depending on the response.
 func (conn *Conn) handleResourcePackClientResponse(pk *packet.ResourcePackClientResponse) error {
+	if pk.Response != packet.PackResponseSendPacks {
+		conn.packStatus(pk.Response)
+	}
 	switch pk.Response {
 	case packet.PackResponseRefused:
 		// Even though this response is never sent, we handle it appropriately in case it is changed to work
 		// correctly again.
+		conn.updatePackResults(ResourcePackPending, ResourcePackRefused)
 		return conn.close(conn.closeErr("resource pack refused"))
 	case packet.PackResponseSendPacks:
 		packs := pk.PacksToDownload
-		conn.packQueue = &resourcePackQueue{packs: conn.resourcePacks}
+		conn.packQueue = &resourcePackQueue{packs: conn.packs(), chunkSize: conn.packChunkSize}
 		if err := conn.packQueue.Request(packs); err != nil {
 			return fmt.Errorf("lookup resource packs by UUID: %w", err)
 		}
+		conn.packMu.Lock()
+		conn.packsRequested = true
+		for id := range conn.packQueue.packsToDownload {
+			conn.packResults[id] = ResourcePackDownloading
+		}
+		for id := range conn.packQueue.packsFromURL {
+			conn.packResults[id] = ResourcePackDownloadURL
+		}
+		conn.packMu.Unlock()
+		if conn.packQueue.AllDownloaded() {
+			// All packs requested have a download URL, so none are sent over the connection and the client
+			// will continue once it has downloaded them.
+			return nil
+		}
 		// Proceed with the first resource pack download. We run all downloads in sequence rather than in
 		// parallel, as it's less prone to packet loss.
 		if err := conn.nextResourcePackDownload(); err != nil {
 			return err
 		}
 	case packet.PackResponseAllPacksDownloaded:
-		pk := &packet.ResourcePackStack{BaseGameVersion: protocol.CurrentVersion, Experiments: []protocol.ExperimentData{{Name: "cameras", Enabled: true}}}
-		for _, pack := range conn.resourcePacks {
+		// If the client requested packs, it left out the packs it already had stored locally. If it did not
+		// request any packs, it either had all of them or declined to download them, which the protocol does
+		// not tell apart. Packs that were requested but not fully sent did not finish downloading.
+		// Packs with a download URL are never sent over the connection, so whether the client has them is not
+		// known.
+		packs := conn.packs()
+		conn.packMu.Lock()
+		for _, pack := range packs {
+			if id := pack.UUID().String(); pack.DownloadURL() != "" && conn.packResults[id] == ResourcePackPending {
+				conn.packResults[id] = ResourcePackDownloadURL
+			}
+		}
+		notRequested := ResourcePackNotRequested
+		if conn.packsRequested {
+			notRequested = ResourcePackCached
+		}
+		conn.packMu.Unlock()
+		conn.updatePackResults(ResourcePackPending, notRequested)
+		conn.updatePackResults(ResourcePackDownloading, ResourcePackFailed)
+
+		pk := &packet.ResourcePackStack{TexturePackRequired: conn.texturePacksRequired, BaseGameVersion: protocol.CurrentVersion, Experiments: []protocol.ExperimentData{{Name: "cameras", Enabled: true}}}
+		for _, pack := range conn.packs() {
 			resourcePack := protocol.StackResourcePack{UUID: pack.UUID().String(), Version: pack.Version()}
-			// If it has behaviours, add it to the behaviour pack list. If not, we add it to the texture packs
-			// list.
-			if pack.HasBehaviours() {
+			// The list is chosen by the pack, which is the behaviour pack list if it has behaviours unless set
+			// otherwise using WithStack. Packs keep the order in which they were added within each list.
+			if pack.Stack() == resource.StackBehaviour {
 				pk.BehaviourPacks = append(pk.BehaviourPacks, resourcePack)
 				continue
 			}
@@ -994,13 +1608,49 @@ func (conn *Conn) handleResourcePackClientResponse(pk *packet.ResourcePackClient
 			return fmt.Errorf("send ResourcePackStack: %w", err)
 		}
 	case packet.PackResponseCompleted:
-		conn.loggedIn = true
+		if conn.sendingPacks.Load() {
+			// The resource packs were sent using SendResourcePacks: The connection was already logged in.
+			conn.packMu.Lock()
+			if conn.packsSent != nil {
+				close(conn.packsSent)
+				conn.packsSent = nil
+			}
+			conn.packMu.Unlock()
+			conn.sendingPacks.Store(false)
+			return nil
+		}
+		conn.setLoggedIn()
 	default:
 		return fmt.Errorf("unknown ResourcePackClientResponse response type %v", pk.Response)
 	}
 	return nil
 }
 
+// packStatus calls the packStatusFunc of the connection, if set, with the response passed and the time
+// passed since the last ResourcePacksInfo packet was sent. The function is called in a separate goroutine so
+// that it cannot block the reading of packets.
+func (conn *Conn) packStatus(response byte) {
+	if conn.packStatusFunc == nil {
+		return
+	}
+	conn.packMu.Lock()
+	d := time.Since(conn.packsInfoSent)
+	conn.packMu.Unlock()
+	go conn.packStatusFunc(conn, response, d)
+}
+
+// updatePackResults changes the result of every resource pack that currently has the result from to the
+// result to.
+func (conn *Conn) updatePackResults(from, to ResourcePackResult) {
+	conn.packMu.Lock()
+	defer conn.packMu.Unlock()
+	for id, res := range conn.packResults {
+		if res == from {
+			conn.packResults[id] = to
+		}
+	}
+}
+
 // startGame sends a StartGame packet using the game data of the connection.
 func (conn *Conn) startGame() {
 	data := conn.gameData
@@ -1059,6 +1709,7 @@ func (conn *Conn) nextResourcePackDownload() error {
 	if err := conn.WritePacket(pk); err != nil {
 		return fmt.Errorf("send ResourcePackDataInfo: %w", err)
 	}
+	conn.log.Debug("sending resource pack", "UUID", pk.UUID, "size", pk.Size, "chunks", pk.ChunkCount)
 	// Set the next expected packet to ResourcePackChunkRequest packets.
 	conn.expect(packet.IDResourcePackChunkRequest)
 	return nil
@@ -1110,9 +1761,6 @@ func (conn *Conn) handleResourcePackDataInfo(pk *packet.ResourcePackDataInfo) er
 				_, _ = pack.buf.Write(frag)
 			}
 		}
-		conn.packMu.Lock()
-		defer conn.packMu.Unlock()
-
 		if pack.buf.Len() != int(pack.size) {
 			conn.log.Error(fmt.Sprintf("download resource pack: incorrect resource pack size: expected %v, got %v", pack.size, pack.buf.Len()), "UUID", id)
 			return
@@ -1123,7 +1771,10 @@ func (conn *Conn) handleResourcePackDataInfo(pk *packet.ResourcePackDataInfo) er
 			conn.log.Error("download resource pack: invalid full resource pack data: "+err.Error(), "UUID", id)
 			return
 		}
+		conn.packMu.Lock()
+		defer conn.packMu.Unlock()
 		conn.packQueue.packAmount--
+		conn.log.Debug("downloaded resource pack", "UUID", id, "remaining", conn.packQueue.packAmount)
 		// Finally we add the resource to the resource packs slice.
 		conn.resourcePacks = append(conn.resourcePacks, newPack.WithContentKey(pack.contentKey))
 		if conn.packQueue.packAmount == 0 {
@@ -1161,28 +1812,46 @@ func (conn *Conn) handleResourcePackChunkData(pk *packet.ResourcePackChunkData)
 // handleResourcePackChunkRequest handles a resource pack chunk request, which requests a part of the resource
 // pack to be downloaded.
 func (conn *Conn) handleResourcePackChunkRequest(pk *packet.ResourcePackChunkRequest) error {
+	id, err := uuid.Parse(pk.UUID)
+	if err != nil {
+		return fmt.Errorf("invalid resource pack UUID %q: %w", pk.UUID, err)
+	}
+	packs := conn.packs()
+	index := slices.IndexFunc(packs, func(pack *resource.Pack) bool { return pack.UUID() == id })
+	if index == -1 {
+		return fmt.Errorf("resource pack %v was not offered", id)
+	}
+	if url := packs[index].DownloadURL(); url != "" {
+		return fmt.Errorf("resource pack %v must be downloaded from %v", id, url)
+	}
 	current := conn.packQueue.currentPack
-	if current.UUID().String() != pk.UUID {
-		return fmt.Errorf("expected pack UUID %v, but got %v", current.UUID(), pk.UUID)
+	if current == nil || current.UUID() != id {
+		return fmt.Errorf("resource pack %v is not currently being downloaded", id)
 	}
-	if conn.packQueue.currentOffset != uint64(pk.ChunkIndex)*packChunkSize {
-		return fmt.Errorf("expected pack UUID %v, but got %v", conn.packQueue.currentOffset/packChunkSize, pk.ChunkIndex)
+	chunkSize := conn.packQueue.chunkSize
+	if conn.packQueue.currentOffset != uint64(pk.ChunkIndex)*chunkSize {
+		return fmt.Errorf("expected chunk index %v, but got %v", conn.packQueue.currentOffset/chunkSize, pk.ChunkIndex)
 	}
 	response := &packet.ResourcePackChunkData{
 		UUID:       pk.UUID,
 		ChunkIndex: pk.ChunkIndex,
 		DataOffset: conn.packQueue.currentOffset,
-		Data:       make([]byte, packChunkSize),
-	}
-	conn.packQueue.currentOffset += packChunkSize
-	// We read the data directly into the response's data.
-	if n, err := current.ReadAt(response.Data, int64(response.DataOffset)); err != nil {
-		// If we hit an EOF, we don't need to return an error, as we've simply reached the end of the content
-		// AKA the last chunk.
-		if err != io.EOF {
-			return fmt.Errorf("read resource pack chunk: %w", err)
-		}
-		response.Data = response.Data[:n]
+		Data:       make([]byte, chunkSize),
+	}
+	conn.packQueue.currentOffset += chunkSize
+	// We read the data directly into the response's data. If we hit an EOF, we don't need to return an
+	// error, as we've simply reached the end of the content AKA the last chunk.
+	n, err := current.ReadAt(response.Data, int64(response.DataOffset))
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read resource pack chunk: %w", err)
+	}
+	response.Data = response.Data[:n]
+	// The last chunk might fill up the chunk size exactly, in which case no EOF is returned, so we check the
+	// offset instead.
+	if response.DataOffset+uint64(n) >= uint64(current.Len()) {
+		conn.packMu.Lock()
+		conn.packResults[id.String()] = ResourcePackDownloaded
+		conn.packMu.Unlock()
 
 		defer func() {
 			if !conn.packQueue.AllDownloaded() {
@@ -1202,6 +1871,7 @@ func (conn *Conn) handleResourcePackChunkRequest(pk *packet.ResourcePackChunkReq
 // handleStartGame handles an incoming StartGame packet. It is the signal that the player has been added to a
 // world, and it obtains most of its dedicated properties.
 func (conn *Conn) handleStartGame(pk *packet.StartGame) error {
+	conn.startGamePacket = pk
 	conn.gameData = GameData{
 		Difficulty:                   pk.Difficulty,
 		WorldName:                    pk.WorldName,
@@ -1325,35 +1995,17 @@ func (conn *Conn) handlePlayStatus(pk *packet.PlayStatus) error {
 		// The next packet we expect is the ResourcePacksInfo packet.
 		conn.expect(packet.IDResourcePacksInfo)
 		return conn.Flush()
-	case packet.PlayStatusLoginFailedClient:
-		_ = conn.close(conn.closeErr("client outdated"))
-		return fmt.Errorf("client outdated")
-	case packet.PlayStatusLoginFailedServer:
-		_ = conn.close(conn.closeErr("server outdated"))
-		return fmt.Errorf("server outdated")
 	case packet.PlayStatusPlayerSpawn:
 		// We've spawned and can send the last packet in the spawn sequence.
 		conn.waitingForSpawn.Store(true)
 		conn.tryFinaliseClientConn()
 		return nil
-	case packet.PlayStatusLoginFailedInvalidTenant:
-		_ = conn.close(conn.closeErr("invalid edu edition game owner"))
-		return fmt.Errorf("invalid edu edition game owner")
-	case packet.PlayStatusLoginFailedVanillaEdu:
-		_ = conn.close(conn.closeErr("cannot join an edu edition game on vanilla"))
-		return fmt.Errorf("cannot join an edu edition game on vanilla")
-	case packet.PlayStatusLoginFailedEduVanilla:
-		_ = conn.close(conn.closeErr("cannot join a vanilla game on edu edition"))
-		return fmt.Errorf("cannot join a vanilla game on edu edition")
-	case packet.PlayStatusLoginFailedServerFull:
-		_ = conn.close(conn.closeErr("server full"))
-		return fmt.Errorf("server full")
-	case packet.PlayStatusLoginFailedEditorVanilla:
-		_ = conn.close(conn.closeErr("cannot join a vanilla game on editor"))
-		return fmt.Errorf("cannot join a vanilla game on editor")
-	case packet.PlayStatusLoginFailedVanillaEditor:
-		_ = conn.close(conn.closeErr("cannot join an editor game on vanilla"))
-		return fmt.Errorf("cannot join an editor game on vanilla")
+	case packet.PlayStatusLoginFailedClient, packet.PlayStatusLoginFailedServer, packet.PlayStatusLoginFailedInvalidTenant,
+		packet.PlayStatusLoginFailedVanillaEdu, packet.PlayStatusLoginFailedEduVanilla, packet.PlayStatusLoginFailedServerFull,
+		packet.PlayStatusLoginFailedEditorVanilla, packet.PlayStatusLoginFailedVanillaEditor:
+		err := loginFailed(pk.Status)
+		_ = conn.close(err)
+		return err
 	default:
 		return fmt.Errorf("unknown play status %v", pk.Status)
 	}
@@ -1368,7 +2020,7 @@ func (conn *Conn) tryFinaliseClientConn() {
 		conn.gameDataReceived.Store(false)
 
 		close(conn.spawn)
-		conn.loggedIn = true
+		conn.setLoggedIn()
 		_ = conn.WritePacket(&packet.SetLocalPlayerAsInitialised{EntityRuntimeID: conn.gameData.EntityRuntimeID})
 	}
 }
@@ -1376,35 +2028,64 @@ func (conn *Conn) tryFinaliseClientConn() {
 // enableEncryption enables encryption on the server side over the connection. It sends an unencrypted
 // handshake packet to the client and enables encryption after that.
 func (conn *Conn) enableEncryption(clientPublicKey *ecdsa.PublicKey) error {
-	signer, _ := jose.NewSigner(jose.SigningKey{Key: conn.privateKey, Algorithm: jose.ES384}, &jose.SignerOptions{
-		ExtraHeaders: map[jose.HeaderKey]any{"x5u": login.MarshalPublicKey(&conn.privateKey.PublicKey)},
+	// We first derive the key so that an invalid client public key is detected before anything is sent.
+	keyBytes, err := conn.deriveKey(clientPublicKey, conn.salt)
+	if err != nil {
+		return err
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&conn.privateKey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMarshalPublicKey, err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Key: conn.privateKey, Algorithm: jose.ES384}, &jose.SignerOptions{
+		ExtraHeaders: map[jose.HeaderKey]any{"x5u": base64.StdEncoding.EncodeToString(pub)},
 	})
+	if err != nil {
+		return fmt.Errorf("%w: create signer: %w", ErrSignHandshake, err)
+	}
 	// We produce an encoded JWT using the header and payload above, then we send the JWT in a ServerToClient-
 	// Handshake packet so that the client can initialise encryption.
 	serverJWT, err := jwt.Signed(signer).Claims(saltClaims{Salt: base64.RawStdEncoding.EncodeToString(conn.salt)}).Serialize()
 	if err != nil {
-		return fmt.Errorf("compact serialise server JWT: %w", err)
+		return fmt.Errorf("%w: compact serialise server JWT: %w", ErrSignHandshake, err)
 	}
 	if err := conn.WritePacket(&packet.ServerToClientHandshake{JWT: []byte(serverJWT)}); err != nil {
-		return fmt.Errorf("send ServerToClientHandshake: %w", err)
+		return fmt.Errorf("%w: write ServerToClientHandshake: %w", ErrHandshakeSend, err)
 	}
 	// Flush immediately as we'll enable encryption after this.
-	_ = conn.Flush()
-
-	// We first compute the shared secret.
-	x, _ := clientPublicKey.Curve.ScalarMult(clientPublicKey.X, clientPublicKey.Y, conn.privateKey.D.Bytes())
-
-	sharedSecret := append(bytes.Repeat([]byte{0}, 48-len(x.Bytes())), x.Bytes()...)
-
-	keyBytes := sha256.Sum256(append(conn.salt, sharedSecret...))
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("%w: flush ServerToClientHandshake: %w", ErrHandshakeSend, err)
+	}
 
 	// Finally we enable encryption for the encoder and decoder using the secret key bytes we produced.
+	conn.storeEncryptionKey(keyBytes)
 	conn.enc.EnableEncryption(keyBytes)
 	conn.dec.EnableEncryption(keyBytes)
 
 	return nil
 }
 
+// deriveKey derives the key used to encrypt the connection from the public key of the other end of the
+// connection and the salt passed. The shared secret is computed using ECDH with the private key of the
+// connection. An error wrapping ErrKeyDerivation is returned if the public key is invalid.
+func (conn *Conn) deriveKey(pub *ecdsa.PublicKey, salt []byte) ([32]byte, error) {
+	remote, err := pub.ECDH()
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("%w: convert public key: %w", ErrKeyDerivation, err)
+	}
+	local, err := conn.privateKey.ECDH()
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("%w: convert private key: %w", ErrKeyDerivation, err)
+	}
+	// The shared secret is always the size of a coordinate of the curve, 48 bytes for P-384.
+	sharedSecret, err := local.ECDH(remote)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("%w: compute shared secret: %w", ErrKeyDerivation, err)
+	}
+	return sha256.Sum256(append(slices.Clip(salt), sharedSecret...)), nil
+}
+
 // expect sets the packet IDs that are next expected to arrive.
 func (conn *Conn) expect(packetIDs ...uint32) {
 	conn.expectedIDs.Store(packetIDs)
@@ -1413,13 +2094,43 @@ func (conn *Conn) expect(packetIDs ...uint32) {
 func (conn *Conn) close(cause error) error {
 	var err error
 	conn.once.Do(func() {
-		err = conn.Flush()
+		conn.log.Debug("closing connection", "cause", cause)
+		// Packets still buffered, such as a Disconnect packet, are sent before closing. The flush is bounded
+		// by closeFlushTimeout, so that closing a connection to a peer that stopped reading does not block
+		// indefinitely.
+		ctx, cancel := context.WithTimeout(context.Background(), closeFlushTimeout)
+		err = conn.FlushContext(ctx)
+		cancel()
 		conn.cancelFunc(cause)
 		_ = conn.conn.Close()
 	})
 	return err
 }
 
+// closeFlushTimeout is the maximum duration spent flushing the packets buffered by a Conn when it is closed.
+const closeFlushTimeout = time.Second * 5
+
+// closeIdle closes the connection with ErrIdleTimeout as cause once no packets were received from it for the
+// timeout passed. closeIdle returns when the connection is closed.
+func (conn *Conn) closeIdle(timeout time.Duration) {
+	conn.lastReceive.CompareAndSwap(0, time.Now().UnixNano())
+
+	ticker := time.NewTicker(max(timeout/4, time.Millisecond*50))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.Sub(time.Unix(0, conn.lastReceive.Load())) >= timeout {
+				conn.log.Debug("closing idle connection", "timeout", timeout)
+				_ = conn.close(ErrIdleTimeout)
+				return
+			}
+		}
+	}
+}
+
 // closeErr returns an adequate connection closed error for the op passed. If the connection was closed
 // through a Disconnect packet, the message is contained.
 func (conn *Conn) closeErr(op string) error {
@@ -0,0 +1,69 @@
+package minecraft_test
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkWritePacket measures writing packets to a Conn and flushing them. Every iteration writes a single
+// packet, and the packets written are flushed after every packet or after a batch of packets. The
+// allocations reported are those per packet written.
+func BenchmarkWritePacket(b *testing.B) {
+	for name, batch := range map[string]int{"FlushEvery": 1, "FlushBatch": 64} {
+		b.Run(name, func(b *testing.B) {
+			benchmarkWritePacket(b, batch)
+		})
+	}
+}
+
+// benchmarkWritePacket writes packets to a client connected to a server in memory and flushes the client
+// after every batch of packets written. The batches flushed are not sent to the server.
+func benchmarkWritePacket(b *testing.B, batch int) {
+	conn := &discardConn{}
+	d := minecraft.Dialer{WrapConn: func(c net.Conn) (net.Conn, error) {
+		conn.Conn = c
+		return conn, nil
+	}}
+	client, server, err := minecraft.Pipe(minecraft.ListenConfig{AuthenticationDisabled: true}, d, minecraft.GameData{})
+	if err != nil {
+		b.Fatalf("pipe: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	// Batches written are discarded, so that only the allocations of the client are measured.
+	conn.discard.Store(true)
+	pk := &packet.SetTime{Time: 6000}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.WritePacket(pk); err != nil {
+			b.Fatalf("write packet: %v", err)
+		}
+		if (i+1)%batch == 0 {
+			if err := client.Flush(); err != nil {
+				b.Fatalf("flush: %v", err)
+			}
+		}
+	}
+	if err := client.Flush(); err != nil {
+		b.Fatalf("flush: %v", err)
+	}
+}
+
+// discardConn is a net.Conn that discards all data written to it once discard is set.
+type discardConn struct {
+	net.Conn
+	discard atomic.Bool
+}
+
+// Write ...
+func (c *discardConn) Write(b []byte) (int, error) {
+	if c.discard.Load() {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
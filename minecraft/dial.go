@@ -34,7 +34,10 @@ import (
 // The zero value of Dialer is used for the package level Dial function.
 type Dialer struct {
 	// ErrorLog is a log.Logger that errors that occur during packet handling of
-	// servers are written to. By default, errors are not logged.
+	// servers are written to. By default, errors are not logged. Events in the
+	// lifetime of the connection, such as logging in, resource packs being
+	// downloaded and the connection being closed along with the cause, are
+	// logged at debug level.
 	ErrorLog *slog.Logger
 
 	// ClientData is the client data used to login to the server with. It includes fields such as the skin,
@@ -53,17 +56,54 @@ type Dialer struct {
 	// If TokenSource is nil, the connection will not use authentication.
 	TokenSource oauth2.TokenSource
 
+	// PrivateKey is the P-384 private key used to sign the login request and to derive the key used to
+	// encrypt the connection. If nil, a new key is generated for every connection dialed, which is
+	// recommended. A key may be reused to avoid the cost of generating one if many connections are dialed
+	// to trusted servers, at the cost of forward secrecy between the connections using it.
+	PrivateKey *ecdsa.PrivateKey
+
 	// PacketFunc is called whenever a packet is read from or written to the connection returned when using
 	// Dialer.Dial(). It includes packets that are otherwise covered in the connection sequence, such as the
 	// Login packet. The function is called with the header of the packet and its raw payload, the address
 	// from which the packet originated, and the destination address.
 	PacketFunc func(header packet.Header, payload []byte, src, dst net.Addr)
 
+	// WrapConn, if set, is called with the net.Conn dialed over the network passed to Dial, before anything is
+	// written to it. The net.Conn returned is used for the connection instead, which allows adding a layer
+	// such as TLS to the transport. The Minecraft protocol, including its encryption, runs on top of the
+	// net.Conn returned. Like the net.Conn passed, this net.Conn must return exactly one message written by
+	// the other end per call to Read. Stream based layers such as a tls.Conn do not do so on their own, nor
+	// can they run directly on a message based net.Conn, so NewStreamConn and NewFramedConn should be used
+	// to add such a layer, for example:
+	//
+	//	d.WrapConn = func(conn net.Conn) (net.Conn, error) {
+	//		return minecraft.NewFramedConn(tls.Client(minecraft.NewStreamConn(conn), tlsConfig)), nil
+	//	}
+	//
+	// Both ends of the connection must wrap their net.Conn in the same way. If WrapConn returns an error, the
+	// dialed net.Conn is closed and dialing fails.
+	WrapConn func(conn net.Conn) (net.Conn, error)
+
+	// CustomPackets is a packet.Pool holding packets that are added to the packet pool of the connection, on
+	// top of the packets of the Protocol. Packets read with an ID registered in CustomPackets are returned as
+	// the packet produced by its function rather than as a *packet.Unknown.
+	CustomPackets packet.Pool
+
 	// DownloadResourcePack is called individually for every texture and behaviour pack sent by the connection when
 	// using Dialer.Dial(), and can be used to stop the pack from being downloaded. The function is called with the UUID
 	// and version of the resource pack, the number of the current pack being downloaded, and the total amount of packs.
-	// The boolean returned determines if the pack will be downloaded or not.
+	// The boolean returned determines if the pack will be downloaded or not. Unlike with ResourcePackPolicy, a pack
+	// declined is skipped, even if the server requires packs to be downloaded. Packs offered with a download URL are
+	// always skipped, as they are not sent over the connection.
 	DownloadResourcePack func(id uuid.UUID, version string, current, total int) bool
+	// ResourcePackPolicy is called for every resource pack sent by the server, with information on the pack
+	// such as its size and whether it has scripts, and whether the server requires packs to be downloaded.
+	// The pack is only downloaded if ResourcePackPolicy returns true, which, for example, allows declining
+	// packs with scripts from untrusted servers. If both ResourcePackPolicy and DownloadResourcePack are set,
+	// a pack is only downloaded if both return true.
+	// If ResourcePackPolicy declines a pack while the server requires packs to be downloaded, the packs are
+	// refused and the connection is closed, just like a vanilla client would.
+	ResourcePackPolicy func(pack protocol.TexturePackInfo, required bool) bool
 
 	// DisconnectOnUnknownPackets specifies if the connection should disconnect if packets received are not present
 	// in the packet pool. If true, such packets lead to the connection being closed immediately.
@@ -88,17 +128,62 @@ type Dialer struct {
 	// will not be flushed automatically. In this case, calling `(*Conn).Flush()` is required after any
 	// calls to `(*Conn).Write()` or `(*Conn).WritePacket()` to send the packets over network.
 	FlushRate time.Duration
+	// Flusher is a Flusher used to flush the packets buffered by the connection. If set, FlushRate is
+	// ignored and the connection is flushed by the goroutine of the Flusher rather than by a goroutine of
+	// its own, which scales better to many connections.
+	Flusher *Flusher
+
+	// MaxQueuedPackets is the maximum amount of packets received by the connection that may be queued while
+	// waiting to be read, for example using Conn.ReadPacket. If the maximum is reached, OverflowPolicy
+	// determines what happens to new packets. If zero, a default of 1024 is used. MaxQueuedPackets has no
+	// effect if OverflowPolicy is OverflowGrow.
+	MaxQueuedPackets int
+	// OverflowPolicy is the OverflowPolicy used by the connection once MaxQueuedPackets packets are queued.
+	// By default, OverflowGrow is used, which never drops packets.
+	OverflowPolicy OverflowPolicy
+
+	// MaxBufferedBytes is the maximum total size in bytes of packets written to the connection that may be
+	// buffered while waiting to be flushed. Once reached, Write and WritePacket return an error until the
+	// buffered packets are flushed. If zero, a default of 64 MiB is used.
+	MaxBufferedBytes int
+	// MaxWriteBatchSize is the maximum size in bytes, before compression, of a batch of packets written to
+	// the connection. When flushing, the packets buffered are split into multiple batches that each stay below
+	// this size, which may help with transports that handle large messages poorly. A single packet larger
+	// than the maximum is still sent in a batch of its own. If zero, all packets are sent in a single batch.
+	MaxWriteBatchSize int
 
 	// EnableClientCache, if set to true, enables the client blob cache for the client. This means that the
 	// server will send chunks as blobs, which may be saved by the client so that chunks don't have to be
 	// transmitted every time, resulting in less network transmission.
 	EnableClientCache bool
 
+	// RespondToLatencyRequests, if set to true, makes the Conn automatically respond to packet.NetworkStackLatency
+	// packets sent by the server that need a response. Some servers use these packets to measure the latency
+	// of the client and kick clients that do not respond. The packets are still returned by Conn.ReadPacket.
+	RespondToLatencyRequests bool
+	// LatencyPingInterval, if non-zero, makes the Conn send a packet.NetworkStackLatency to the server every
+	// LatencyPingInterval once spawned, so that the round trip time of the connection is measured. The
+	// measurements are available through Conn.RoundTripTime and Conn.Latency. The responses to these packets
+	// are not returned by Conn.ReadPacket. No round trip time is measured if the server does not respond to
+	// the packets.
+	LatencyPingInterval time.Duration
+
+	// MaxTransfers, if non-zero, makes a Conn obtained using the Dialer handle packet.Transfer packets sent by
+	// the server. Instead of being returned by Conn.ReadPacket, a Transfer closes the Conn with a
+	// TransferError holding the address of the new server, after which Dialer.FollowTransfer may be used to
+	// connect to it. MaxTransfers is the maximum amount of transfers followed starting from the connection
+	// originally dialed, which prevents servers from transferring the client in a loop.
+	MaxTransfers int
+
 	// KeepXBLIdentityData, if set to true, enables passing XUID and title ID to the target server
 	// if the authentication token is not set. This is technically not valid and some servers might kick
 	// the client when an XUID is present without logging in.
 	// For getting this to work with BDS, authentication should be disabled.
 	KeepXBLIdentityData bool
+
+	// chains, if non-nil, caches the authentication chain obtained using the TokenSource. It is set by a
+	// DialerPool only if PrivateKey is set, as the chain is bound to the key.
+	chains *chainCache
 }
 
 // Dial dials a Minecraft connection to the address passed over the network passed. The network is typically
@@ -139,7 +224,9 @@ func (d Dialer) Dial(network, address string) (*Conn, error) {
 
 // DialTimeout dials a Minecraft connection to the address passed over the network passed. The network is
 // typically "raknet". A Conn is returned which may be used to receive packets from and send packets to.
-// If a connection is not established before the timeout ends, DialTimeout returns an error.
+// If a connection is not established before the timeout ends, DialTimeout returns an error. The timeout
+// covers the entire connection sequence, including the login, encryption handshake and resource pack
+// download, and the error returned describes the phase of the sequence in which the timeout was reached.
 func (d Dialer) DialTimeout(network, address string, timeout time.Duration) (*Conn, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -161,13 +248,21 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 		d.FlushRate = time.Second / 20
 	}
 
-	key, err := ecdsa.GenerateKey(elliptic.P384(), cryptorand.Reader)
-	if err != nil {
-		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("generating ECDSA key: %w", err)}
+	key := d.PrivateKey
+	if key == nil {
+		if key, err = ecdsa.GenerateKey(elliptic.P384(), cryptorand.Reader); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("generating ECDSA key: %w", err)}
+		}
+	} else if key.Curve != elliptic.P384() {
+		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("private key: curve %v is not P-384", key.Curve.Params().Name)}
 	}
 	var chainData string
 	if d.TokenSource != nil {
-		chainData, err = authChain(ctx, d.TokenSource, key)
+		if d.chains != nil {
+			chainData, err = d.chains.chain(ctx, d.TokenSource, key)
+		} else {
+			chainData, err = authChain(ctx, d.TokenSource, key)
+		}
 		if err != nil {
 			return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: err}
 		}
@@ -193,16 +288,44 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	if err != nil {
 		return nil, err
 	}
+	if d.WrapConn != nil {
+		wrapped, err := d.WrapConn(netConn)
+		if err != nil {
+			_ = netConn.Close()
+			return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("wrap connection: %w", err)}
+		}
+		netConn = wrapped
+	}
 
-	conn = newConn(netConn, key, d.ErrorLog, d.Protocol, d.FlushRate, false)
-	conn.pool = conn.proto.Packets(false)
+	flushRate := d.FlushRate
+	if d.Flusher != nil {
+		flushRate = -1
+	}
+	conn = newConn(netConn, key, d.ErrorLog, d.Protocol, flushRate, false)
+	if d.Flusher != nil {
+		d.Flusher.add(conn)
+	}
+	conn.customPackets = d.CustomPackets
+	conn.pool = conn.packetPool(false)
 	conn.identityData = d.IdentityData
 	conn.clientData = d.ClientData
 	conn.packetFunc = d.PacketFunc
 	conn.downloadResourcePack = d.DownloadResourcePack
+	conn.resourcePackPolicy = d.ResourcePackPolicy
 	conn.cacheEnabled = d.EnableClientCache
+	conn.respondToLatency = d.RespondToLatencyRequests
+	conn.network, conn.address = network, address
+	conn.handleTransfers = d.MaxTransfers > 0
+	if d.LatencyPingInterval > 0 {
+		conn.startPinging(d.LatencyPingInterval)
+	}
 	conn.disconnectOnInvalidPacket = d.DisconnectOnInvalidPackets
 	conn.disconnectOnUnknownPacket = d.DisconnectOnUnknownPackets
+	conn.setOverflowPolicy(d.OverflowPolicy, d.MaxQueuedPackets)
+	if d.MaxBufferedBytes > 0 {
+		conn.maxBufferedBytes = d.MaxBufferedBytes
+	}
+	conn.enc.SetMaxBatchBytes(d.MaxWriteBatchSize)
 
 	defaultIdentityData(&conn.identityData)
 	defaultClientData(address, conn.identityData.DisplayName, &conn.clientData)
@@ -240,7 +363,7 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 
 	select {
 	case <-ctx.Done():
-		return nil, conn.wrap(context.Cause(ctx), "dial")
+		return nil, conn.wrap(fmt.Errorf("%v: %w", conn.dialPhase(), context.Cause(ctx)), "dial")
 	case <-conn.ctx.Done():
 		return nil, conn.closeErr("dial")
 	case <-readyForLogin:
@@ -253,7 +376,7 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 
 		select {
 		case <-ctx.Done():
-			return nil, conn.wrap(context.Cause(ctx), "dial")
+			return nil, conn.wrap(fmt.Errorf("%v: %w", conn.dialPhase(), context.Cause(ctx)), "dial")
 		case <-conn.ctx.Done():
 			return nil, conn.closeErr("dial")
 		case <-connected:
@@ -263,6 +386,28 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	}
 }
 
+// dialPhase returns a description of the phase of the login sequence that a connection being dialed is in,
+// based on the packets it next expects to receive from the server. It is used to describe where a dial that
+// timed out or failed was stalled.
+func (conn *Conn) dialPhase() string {
+	ids, _ := conn.expectedIDs.Load().([]uint32)
+	if len(ids) == 0 {
+		return "during login"
+	}
+	switch ids[0] {
+	case packet.IDNetworkSettings:
+		return "during network settings request"
+	case packet.IDServerToClientHandshake, packet.IDResourcePacksInfo:
+		return "during login"
+	case packet.IDResourcePackDataInfo, packet.IDResourcePackStack:
+		return "during resource pack download"
+	case packet.IDStartGame, packet.IDItemRegistry:
+		return "during start game"
+	default:
+		return "during spawn"
+	}
+}
+
 // readChainIdentityData reads a login.IdentityData from the Mojang chain
 // obtained through authentication.
 func readChainIdentityData(chainData []byte) (login.IdentityData, error) {
@@ -305,17 +450,22 @@ func listenConn(conn *Conn, readyForLogin, connected chan struct{}, cancel conte
 				} else {
 					conn.log.Error(err.Error())
 				}
+				// Close the connection with the error as cause so that it is returned by subsequent calls
+				// to methods such as ReadPacket.
+				_ = conn.close(err)
 			}
 			return
 		}
-		for _, data := range packets {
-			loggedInBefore, readyToLoginBefore := conn.loggedIn, conn.readyToLogin
-			if err := conn.receive(data); err != nil {
+		conn.stats.batchesRead.Add(1)
+		for i, data := range packets {
+			loggedInBefore, readyToLoginBefore := conn.loggedIn.Load(), conn.readyToLogin
+			if err := conn.receive(data, i == len(packets)-1); err != nil {
 				if cancelContext {
 					cancel(err)
 				} else {
 					conn.log.Error(err.Error())
 				}
+				_ = conn.close(err)
 				return
 			}
 			if !readyToLoginBefore && conn.readyToLogin {
@@ -323,7 +473,7 @@ func listenConn(conn *Conn, readyForLogin, connected chan struct{}, cancel conte
 				// it may be detected.
 				readyForLogin <- struct{}{}
 			}
-			if !loggedInBefore && conn.loggedIn {
+			if !loggedInBefore && conn.loggedIn.Load() {
 				// This is the signal that the connection was considered logged in, so we put a value in the channel so
 				// that it may be detected.
 				cancelContext = false
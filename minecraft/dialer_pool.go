@@ -0,0 +1,342 @@
+package minecraft
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrBackendUnhealthy is returned, wrapped, by DialerPool.Get if the backend server dialed failed too many
+// times in a row and is not yet retried.
+var ErrBackendUnhealthy = errors.New("backend unhealthy")
+
+const (
+	// poolIdleTimeout is the duration after which a Conn put back into a DialerPool is closed if it was not
+	// taken out again.
+	poolIdleTimeout = time.Second * 30
+	// poolMaxFailures is the amount of consecutive failures after which a backend is considered unhealthy.
+	poolMaxFailures = 3
+	// poolRetryInterval is the duration after the last failure of an unhealthy backend after which it is
+	// dialed again.
+	poolRetryInterval = time.Second * 10
+	// chainCacheDuration is the duration for which an authentication chain is reused. Chains remain valid
+	// for considerably longer, but are refreshed regularly so that changes to the account are picked up.
+	chainCacheDuration = time.Hour
+)
+
+// DialerPool dials connections to backend servers on behalf of a proxy, amortising the cost of dialing where
+// it is safe to do so, and tracks the health of every backend dialed.
+//
+// Unless the Dialer has a PrivateKey, a new private key is generated for every connection dialed, like Dial
+// does. If Dialer.PrivateKey is set and the Dialer has a TokenSource, the Minecraft authentication chain
+// obtained using it is cached and shared by all connections, so that no chain is requested for every dial.
+// This is safe, because the chain only proves the identity of the account and that the key belongs to it.
+// The encryption keys of a connection, on the other hand, are derived from a salt chosen by the server for
+// every connection, so the login sequence is still performed in full for every Conn dialed.
+//
+// A Conn may be put back into the DialerPool using Put and taken out again by Get. Such a Conn is still
+// logged in to the server as the player of the Dialer and is still in the world it spawned in, and packets
+// received while it was in the pool remain queued to be read. Connections are therefore only safe to reuse
+// by code acting on behalf of the same player, such as a proxy reconnecting the same client to a backend.
+// They must never be handed to a different client. A Conn put back into the pool is closed and removed from
+// the pool if it is not taken out again within 30 seconds.
+//
+// A backend is considered unhealthy after three consecutive failed dials or checks, after which Get fails
+// immediately with an error wrapping ErrBackendUnhealthy until ten seconds passed since the last failure.
+// A DialerPool is safe for concurrent use.
+type DialerPool struct {
+	network string
+	d       Dialer
+
+	mu       sync.Mutex
+	closed   bool
+	backends map[string]*poolBackend
+	maxIdle  int
+}
+
+// poolBackend holds the idle connections and the health of a single backend of a DialerPool.
+type poolBackend struct {
+	idle   []idleConn
+	health BackendHealth
+}
+
+// idleConn is a Conn put back into a DialerPool, together with the time at which it was put back and the
+// timer that closes it once it expires.
+type idleConn struct {
+	conn  *Conn
+	since time.Time
+	timer *time.Timer
+}
+
+// BackendHealth holds the health of a backend server dialed by a DialerPool.
+type BackendHealth struct {
+	// Address is the address of the backend, as passed to DialerPool.Get.
+	Address string
+	// Healthy is false if the backend failed too many times in a row.
+	Healthy bool
+	// Failures is the amount of consecutive dials or checks of the backend that failed.
+	Failures int
+	// LastError is the error of the last failed dial or check. It is nil if the backend never failed.
+	LastError error
+	// LastSuccess and LastFailure are the times of the last successful and failed dial or check.
+	LastSuccess, LastFailure time.Time
+	// Idle is the amount of connections to the backend currently held by the DialerPool.
+	Idle int
+}
+
+// NewDialerPool creates a DialerPool that dials connections over the network passed using the Dialer
+// passed. At most maxIdle connections put back using Put are held for every backend. An error is returned if
+// the PrivateKey of the Dialer is not a P-384 key.
+func NewDialerPool(network string, d Dialer, maxIdle int) (*DialerPool, error) {
+	if d.PrivateKey != nil {
+		if d.PrivateKey.Curve != elliptic.P384() {
+			return nil, fmt.Errorf("private key: curve %v is not P-384", d.PrivateKey.Curve.Params().Name)
+		}
+		// The authentication chain is bound to the key, so it may only be shared if every connection uses
+		// the same key.
+		d.chains = &chainCache{}
+	}
+	return &DialerPool{network: network, d: d, maxIdle: maxIdle, backends: make(map[string]*poolBackend)}, nil
+}
+
+// Get returns a Conn connected to the backend at the address passed. If a Conn to the backend was put back
+// into the DialerPool, it is returned. Otherwise, a new Conn is dialed using ctx. If the backend is unhealthy,
+// an error wrapping ErrBackendUnhealthy is returned without dialing.
+func (p *DialerPool) Get(ctx context.Context, address string) (*Conn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, &net.OpError{Op: "get", Net: "minecraft", Err: net.ErrClosed}
+	}
+	b := p.backend(address)
+	expired := b.sweep(time.Now())
+	var conn *Conn
+	for len(b.idle) > 0 && conn == nil {
+		c := b.idle[len(b.idle)-1]
+		b.idle = b.idle[:len(b.idle)-1]
+		c.timer.Stop()
+		if c.conn.Err() == nil && c.conn.ctx.Err() == nil {
+			conn = c.conn
+			continue
+		}
+		expired = append(expired, c.conn)
+	}
+	health := b.health
+	p.mu.Unlock()
+	closeConns(expired)
+
+	if conn != nil {
+		return conn, nil
+	}
+	if !health.Healthy && time.Since(health.LastFailure) < poolRetryInterval {
+		return nil, &net.OpError{Op: "get", Net: "minecraft", Err: fmt.Errorf("%w: %v", ErrBackendUnhealthy, health.LastError)}
+	}
+	conn, err := p.d.DialContext(ctx, p.network, address)
+	if ctx.Err() == nil {
+		// A dial cancelled through ctx says nothing about the health of the backend.
+		p.report(address, err)
+	}
+	return conn, err
+}
+
+// Put puts a Conn obtained using Get back into the DialerPool, so that it may be returned by a later call to
+// Get for the same backend. Connections that are closed, that were not obtained using the DialerPool, or
+// for which the DialerPool holds no more room are closed instead.
+func (p *DialerPool) Put(conn *Conn) {
+	p.mu.Lock()
+	if p.closed || conn.Err() != nil || conn.ctx.Err() != nil {
+		p.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	b, ok := p.backends[conn.address]
+	if !ok || conn.network != p.network {
+		p.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	// Connections that expired are removed first, so that they do not take up the room of conn.
+	expired := b.sweep(time.Now())
+	if len(b.idle) >= p.maxIdle {
+		expired = append(expired, conn)
+	} else {
+		address := conn.address
+		b.idle = append(b.idle, idleConn{conn: conn, since: time.Now(), timer: time.AfterFunc(poolIdleTimeout, func() {
+			p.expire(address, conn)
+		})})
+	}
+	p.mu.Unlock()
+	closeConns(expired)
+}
+
+// expire removes the Conn passed from the idle connections of the backend at the address passed and closes
+// it. It is called once the Conn was idle for poolIdleTimeout. If the Conn was taken out of the DialerPool in
+// the meantime, expire does nothing.
+func (p *DialerPool) expire(address string, conn *Conn) {
+	p.mu.Lock()
+	b, ok := p.backends[address]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	i := slices.IndexFunc(b.idle, func(c idleConn) bool { return c.conn == conn })
+	if i == -1 {
+		p.mu.Unlock()
+		return
+	}
+	b.idle = slices.Delete(b.idle, i, i+1)
+	p.mu.Unlock()
+	_ = conn.Close()
+}
+
+// Check pings the backend at the address passed to check if it is online and updates its health
+// accordingly. The error returned is nil if the backend responded to the ping.
+func (p *DialerPool) Check(ctx context.Context, address string) error {
+	n, ok := networkByID(p.network, p.d.ErrorLog)
+	if !ok {
+		return &net.OpError{Op: "check", Net: "minecraft", Err: fmt.Errorf("no network under id %v", p.network)}
+	}
+	_, err := n.PingContext(ctx, address)
+	if err != nil {
+		err = &net.OpError{Op: "check", Net: "minecraft", Err: err}
+	}
+	p.report(address, err)
+	return err
+}
+
+// Health returns the health of the backend at the address passed. A backend that was never dialed or
+// checked is reported as healthy.
+func (p *DialerPool) Health(address string) BackendHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.backends[address]
+	if !ok {
+		return BackendHealth{Address: address, Healthy: true}
+	}
+	health := b.health
+	health.Idle = len(b.idle)
+	return health
+}
+
+// Close closes the DialerPool and all connections held by it. Connections obtained using Get that were not
+// put back are not closed. Get returns an error once the DialerPool is closed.
+func (p *DialerPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	var conns []*Conn
+	for _, b := range p.backends {
+		for _, c := range b.idle {
+			c.timer.Stop()
+			conns = append(conns, c.conn)
+		}
+		b.idle = nil
+	}
+	p.mu.Unlock()
+	closeConns(conns)
+	return nil
+}
+
+// report updates the health of the backend at the address passed using the result of a dial or check.
+func (p *DialerPool) report(address string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b := p.backend(address)
+	if err == nil {
+		b.health.Healthy, b.health.Failures, b.health.LastSuccess = true, 0, time.Now()
+		return
+	}
+	b.health.Failures++
+	b.health.LastError, b.health.LastFailure = err, time.Now()
+	b.health.Healthy = b.health.Failures < poolMaxFailures
+}
+
+// backend returns the poolBackend for the address passed, creating it if it does not yet exist. p.mu must
+// be held when calling backend.
+func (p *DialerPool) backend(address string) *poolBackend {
+	b, ok := p.backends[address]
+	if !ok {
+		b = &poolBackend{health: BackendHealth{Address: address, Healthy: true}}
+		p.backends[address] = b
+	}
+	return b
+}
+
+// sweep removes the idle connections of the poolBackend that expired at the time passed and returns them, so
+// that they may be closed once p.mu is released. Expired connections are normally closed by their timer, but
+// may still be present if the timer has not yet run.
+func (b *poolBackend) sweep(now time.Time) []*Conn {
+	var expired []*Conn
+	b.idle = slices.DeleteFunc(b.idle, func(c idleConn) bool {
+		if now.Sub(c.since) < poolIdleTimeout {
+			return false
+		}
+		c.timer.Stop()
+		expired = append(expired, c.conn)
+		return true
+	})
+	return expired
+}
+
+// closeConns closes all connections passed. Closing a Conn may block while its buffered packets are flushed,
+// so closeConns must not be called while holding the mutex of a DialerPool.
+func closeConns(conns []*Conn) {
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+// chainCache caches the authentication chain obtained using a TokenSource for a single private key.
+type chainCache struct {
+	mu      sync.Mutex
+	data    string
+	expires time.Time
+	// pending is closed once the request for a new chain that is in progress finishes. It is nil if no
+	// request is in progress.
+	pending chan struct{}
+}
+
+// chain returns the cached authentication chain, or obtains a new one using authChain if none was cached
+// yet or if the cached chain expired. The mutex of the chainCache is not held while the chain is requested,
+// so a slow request does not block other calls: These wait for the request in progress until ctx is done,
+// and make a request themselves if it failed.
+func (c *chainCache) chain(ctx context.Context, src oauth2.TokenSource, key *ecdsa.PrivateKey) (string, error) {
+	for {
+		c.mu.Lock()
+		if c.data != "" && time.Now().Before(c.expires) {
+			data := c.data
+			c.mu.Unlock()
+			return data, nil
+		}
+		if pending := c.pending; pending != nil {
+			c.mu.Unlock()
+			select {
+			case <-pending:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		pending := make(chan struct{})
+		c.pending = pending
+		c.mu.Unlock()
+
+		data, err := authChain(ctx, src, key)
+
+		c.mu.Lock()
+		if err == nil {
+			c.data, c.expires = data, time.Now().Add(chainCacheDuration)
+		}
+		c.pending = nil
+		c.mu.Unlock()
+		close(pending)
+		return data, err
+	}
+}
@@ -0,0 +1,66 @@
+package minecraft_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"golang.org/x/oauth2"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestDialerPoolSlowAuthentication checks that a DialerPool.Get waiting for an authentication chain that is
+// still being requested by another call returns once its context is done, rather than being blocked until
+// the other request finishes.
+func TestDialerPoolSlowAuthentication(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	src := &blockingTokenSource{entered: make(chan struct{}), release: make(chan struct{})}
+	pool, err := minecraft.NewDialerPool("pipe", minecraft.Dialer{TokenSource: src, PrivateKey: key}, 1)
+	if err != nil {
+		t.Fatalf("new dialer pool: %v", err)
+	}
+	defer pool.Close()
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := pool.Get(context.Background(), "127.0.0.1:1")
+		first <- err
+	}()
+	<-src.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	if _, err := pool.Get(ctx, "127.0.0.1:1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("get during slow authentication: expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > time.Second*5 {
+		t.Errorf("get during slow authentication: returned after %v", d)
+	}
+
+	close(src.release)
+	if err := <-first; err == nil {
+		t.Errorf("get with failing token source: expected an error")
+	}
+}
+
+// blockingTokenSource is an oauth2.TokenSource of which the Token method blocks until release is closed,
+// after which it returns an error. entered is closed once Token is first called.
+type blockingTokenSource struct {
+	entered, release chan struct{}
+	once             sync.Once
+}
+
+// Token ...
+func (src *blockingTokenSource) Token() (*oauth2.Token, error) {
+	src.once.Do(func() { close(src.entered) })
+	<-src.release
+	return nil, errors.New("no token")
+}
@@ -0,0 +1,186 @@
+package minecraft
+
+import (
+	"errors"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"strings"
+)
+
+// DisconnectReason is the reason for which a connection was closed, as derived from a packet.Disconnect or a
+// packet.PlayStatus holding a login failure. It may be obtained using Conn.DisconnectReason.
+type DisconnectReason int
+
+const (
+	// DisconnectReasonUnknown is the reason of connections that are still open, or that were closed for a
+	// reason that could not be determined.
+	DisconnectReasonUnknown DisconnectReason = iota
+	// DisconnectReasonKicked is the reason of connections closed through a packet.Disconnect with a custom
+	// message, such as one set by a plugin of the server.
+	DisconnectReasonKicked
+	// DisconnectReasonNoReason is the reason of connections closed through a packet.Disconnect without a
+	// message.
+	DisconnectReasonNoReason
+	// DisconnectReasonServerFull is the reason of connections rejected because the server was full.
+	DisconnectReasonServerFull
+	// DisconnectReasonClientOutdated is the reason of connections rejected because the client used an older
+	// protocol than the server.
+	DisconnectReasonClientOutdated
+	// DisconnectReasonServerOutdated is the reason of connections rejected because the server used an older
+	// protocol than the client.
+	DisconnectReasonServerOutdated
+	// DisconnectReasonNotAllowed is the reason of connections rejected because the player is not on the
+	// allow list of the server.
+	DisconnectReasonNotAllowed
+	// DisconnectReasonNotAuthenticated is the reason of connections rejected because the player was not
+	// authenticated with XBOX Live while the server requires it.
+	DisconnectReasonNotAuthenticated
+	// DisconnectReasonLoggedInElsewhere is the reason of connections closed because the same player logged
+	// in to the server from another location.
+	DisconnectReasonLoggedInElsewhere
+	// DisconnectReasonInvalidSkin is the reason of connections rejected because the skin of the player was
+	// invalid.
+	DisconnectReasonInvalidSkin
+	// DisconnectReasonTimeout is the reason of connections closed because the other end stopped responding.
+	DisconnectReasonTimeout
+	// DisconnectReasonInvalidTenant is the reason of connections rejected because the education edition
+	// game owner of the client was invalid.
+	DisconnectReasonInvalidTenant
+	// DisconnectReasonVanillaEdu is the reason of connections from a vanilla client rejected by an
+	// education edition server.
+	DisconnectReasonVanillaEdu
+	// DisconnectReasonEduVanilla is the reason of connections from an education edition client rejected by
+	// a vanilla server.
+	DisconnectReasonEduVanilla
+	// DisconnectReasonEditorVanilla is the reason of connections from an editor client rejected by a vanilla
+	// server.
+	DisconnectReasonEditorVanilla
+	// DisconnectReasonVanillaEditor is the reason of connections from a vanilla client rejected by an
+	// editor server.
+	DisconnectReasonVanillaEditor
+	// DisconnectReasonTransferred is the reason of connections closed because the server transferred the
+	// client to another server, as reported by a TransferError.
+	DisconnectReasonTransferred
+)
+
+// String returns a description of the DisconnectReason, such as 'server full'.
+func (r DisconnectReason) String() string {
+	switch r {
+	case DisconnectReasonKicked:
+		return "kicked"
+	case DisconnectReasonNoReason:
+		return "disconnected without reason"
+	case DisconnectReasonServerFull:
+		return "server full"
+	case DisconnectReasonClientOutdated:
+		return "client outdated"
+	case DisconnectReasonServerOutdated:
+		return "server outdated"
+	case DisconnectReasonNotAllowed:
+		return "not allowed"
+	case DisconnectReasonNotAuthenticated:
+		return "not authenticated"
+	case DisconnectReasonLoggedInElsewhere:
+		return "logged in from other location"
+	case DisconnectReasonInvalidSkin:
+		return "invalid skin"
+	case DisconnectReasonTimeout:
+		return "timed out"
+	case DisconnectReasonInvalidTenant:
+		return "invalid edu edition game owner"
+	case DisconnectReasonVanillaEdu:
+		return "cannot join an edu edition game on vanilla"
+	case DisconnectReasonEduVanilla:
+		return "cannot join a vanilla game on edu edition"
+	case DisconnectReasonEditorVanilla:
+		return "cannot join a vanilla game on editor"
+	case DisconnectReasonVanillaEditor:
+		return "cannot join an editor game on vanilla"
+	case DisconnectReasonTransferred:
+		return "transferred"
+	default:
+		return "unknown"
+	}
+}
+
+// disconnectMessages maps the translation keys sent by vanilla servers in the message of a packet.Disconnect
+// to the DisconnectReason they represent.
+var disconnectMessages = map[string]DisconnectReason{
+	"disconnectionScreen.noReason":                    DisconnectReasonNoReason,
+	"disconnectionScreen.serverFull":                  DisconnectReasonServerFull,
+	"disconnectionScreen.outdatedClient":              DisconnectReasonClientOutdated,
+	"disconnectionScreen.outdatedServer":              DisconnectReasonServerOutdated,
+	"disconnectionScreen.notAllowed":                  DisconnectReasonNotAllowed,
+	"disconnectionScreen.notAuthenticated":            DisconnectReasonNotAuthenticated,
+	"disconnectionScreen.loggedinOtherLocation":       DisconnectReasonLoggedInElsewhere,
+	"disconnectionScreen.invalidSkin":                 DisconnectReasonInvalidSkin,
+	"disconnectionScreen.timeout":                     DisconnectReasonTimeout,
+	"disconnectionScreen.invalidTenant":               DisconnectReasonInvalidTenant,
+	"disconnectionScreen.editionMismatchVanillaToEdu": DisconnectReasonVanillaEdu,
+	"disconnectionScreen.editionMismatchEduToVanilla": DisconnectReasonEduVanilla,
+}
+
+// DisconnectReasonFromMessage returns the DisconnectReason matching the message of a packet.Disconnect. The
+// translation keys used by vanilla servers, such as 'disconnectionScreen.serverFull', are mapped to the
+// reason they represent. An empty message results in DisconnectReasonNoReason, and any other message in
+// DisconnectReasonKicked.
+func DisconnectReasonFromMessage(message string) DisconnectReason {
+	message = strings.TrimPrefix(strings.TrimSpace(message), "%")
+	if message == "" {
+		return DisconnectReasonNoReason
+	}
+	if r, ok := disconnectMessages[message]; ok {
+		return r
+	}
+	return DisconnectReasonKicked
+}
+
+// DisconnectReasonFromPlayStatus returns the DisconnectReason matching the status of a packet.PlayStatus. If
+// the status is not a login failure, DisconnectReasonUnknown and false are returned.
+func DisconnectReasonFromPlayStatus(status int32) (DisconnectReason, bool) {
+	switch status {
+	case packet.PlayStatusLoginFailedClient:
+		return DisconnectReasonClientOutdated, true
+	case packet.PlayStatusLoginFailedServer:
+		return DisconnectReasonServerOutdated, true
+	case packet.PlayStatusLoginFailedInvalidTenant:
+		return DisconnectReasonInvalidTenant, true
+	case packet.PlayStatusLoginFailedVanillaEdu:
+		return DisconnectReasonVanillaEdu, true
+	case packet.PlayStatusLoginFailedEduVanilla:
+		return DisconnectReasonEduVanilla, true
+	case packet.PlayStatusLoginFailedServerFull:
+		return DisconnectReasonServerFull, true
+	case packet.PlayStatusLoginFailedEditorVanilla:
+		return DisconnectReasonEditorVanilla, true
+	case packet.PlayStatusLoginFailedVanillaEditor:
+		return DisconnectReasonVanillaEditor, true
+	}
+	return DisconnectReasonUnknown, false
+}
+
+// DisconnectReason returns the reason for which the connection was closed. It is derived from the
+// DisconnectError or LoginFailedError returned by Err, is DisconnectReasonTransferred if Err returns a
+// TransferError or is DisconnectReasonTimeout if the connection was closed for being idle. If the connection
+// is still open or was closed for another reason, such as by calling Close, DisconnectReasonUnknown is
+// returned.
+func (conn *Conn) DisconnectReason() DisconnectReason {
+	err := conn.Err()
+	var (
+		disconnectErr DisconnectError
+		loginErr      LoginFailedError
+		transferErr   TransferError
+	)
+	switch {
+	case err == nil:
+		return DisconnectReasonUnknown
+	case errors.As(err, &disconnectErr):
+		return disconnectErr.Reason()
+	case errors.As(err, &loginErr):
+		return loginErr.Reason
+	case errors.As(err, &transferErr):
+		return DisconnectReasonTransferred
+	case errors.Is(err, ErrIdleTimeout):
+		return DisconnectReasonTimeout
+	}
+	return DisconnectReasonUnknown
+}
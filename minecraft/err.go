@@ -2,11 +2,39 @@ package minecraft
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"strconv"
 )
 
 var errBufferTooSmall = errors.New("a message sent was larger than the buffer used to receive the message into")
 
+var (
+	// ErrMarshalPublicKey is returned, wrapped, when the server fails to marshal its public key, which is
+	// sent to the client to enable encryption.
+	ErrMarshalPublicKey = errors.New("marshal public key")
+	// ErrSignHandshake is returned, wrapped, when the server fails to sign or serialise the JWT holding its
+	// public key and salt, which is sent to the client to enable encryption.
+	ErrSignHandshake = errors.New("sign handshake")
+	// ErrHandshakeSend is returned, wrapped, when the ServerToClientHandshake packet used to enable encryption
+	// could not be sent to the client.
+	ErrHandshakeSend = errors.New("send handshake")
+	// ErrKeyDerivation is returned, wrapped, when the shared secret or the encryption key could not be derived
+	// from the public key of the other end of the connection, for example because the key is invalid.
+	ErrKeyDerivation = errors.New("derive encryption key")
+	// ErrIdleTimeout is the cause of a connection being closed because no packets were received from it for
+	// longer than ListenConfig.IdleTimeout. Its Timeout method returns true.
+	ErrIdleTimeout error = idleTimeoutError{}
+)
+
+// idleTimeoutError is the type of ErrIdleTimeout. It implements the Timeout method so that errors wrapping
+// it, such as a net.OpError, are recognised as timeouts.
+type idleTimeoutError struct{}
+
+func (idleTimeoutError) Error() string   { return "idle timeout: no packets received" }
+func (idleTimeoutError) Timeout() bool   { return true }
+func (idleTimeoutError) Temporary() bool { return false }
+
 // wrap wraps the error passed into a net.OpError with the op as operation and returns it, or nil if the error
 // passed is nil.
 func (conn *Conn) wrap(err error, op string) error {
@@ -22,6 +50,35 @@ func (conn *Conn) wrap(err error, op string) error {
 	}
 }
 
+// InvalidPacketError is the error that occurs if a packet received by a Conn could not be decoded, for example
+// because it held too few or too many bytes. Unless invalid packets are allowed, the Conn is closed with the
+// InvalidPacketError as cause, so that it is returned, wrapped, by Conn.Err and by subsequent reads and
+// writes. It may be obtained from these errors using errors.As.
+type InvalidPacketError struct {
+	// PacketID is the ID of the packet that could not be decoded.
+	PacketID uint32
+	// Type is the name of the type of the packet that the ID of the packet was resolved to using the packet
+	// pool of the Conn, such as '*packet.Text'.
+	Type string
+	// Offset is the offset in the payload of the packet, excluding the header, at which decoding failed. If
+	// the packet held too many bytes, Offset is the amount of bytes that were decoded.
+	Offset int
+	// Size is the total size of the payload of the packet, excluding the header.
+	Size int
+	// Err is the error that occurred while decoding the packet.
+	Err error
+}
+
+// Error ...
+func (err InvalidPacketError) Error() string {
+	return fmt.Sprintf("decode packet %v (ID=%v) at offset %v/%v: %v", err.Type, err.PacketID, err.Offset, err.Size, err.Err)
+}
+
+// Unwrap returns the error that occurred while decoding the packet.
+func (err InvalidPacketError) Unwrap() error {
+	return err.Err
+}
+
 // DisconnectError is an error returned by operations from Conn when the connection is closed by the other
 // end through a packet.Disconnect. It is wrapped in a net.OpError and may be obtained using
 // errors.Unwrap(net.OpError).
@@ -31,3 +88,93 @@ type DisconnectError string
 func (d DisconnectError) Error() string {
 	return string(d)
 }
+
+// Is returns true if target is net.ErrClosed, so that errors.Is(err, net.ErrClosed) remains true for errors
+// returned because the connection was disconnected.
+func (d DisconnectError) Is(target error) bool {
+	return target == net.ErrClosed
+}
+
+// Reason returns the DisconnectReason matching the message held in the packet.Disconnect.
+func (d DisconnectError) Reason() DisconnectReason {
+	return DisconnectReasonFromMessage(string(d))
+}
+
+// LoginFailedError is the error that a Conn is closed with if its login failed with a packet.PlayStatus
+// holding a login failure, either because the server sent one to the client or because FailLogin was
+// called. Like DisconnectError, it is returned by Conn.Err and, wrapped, by subsequent reads and writes.
+type LoginFailedError struct {
+	// Status is the status of the packet.PlayStatus, such as packet.PlayStatusLoginFailedServerFull.
+	Status int32
+	// Reason is the DisconnectReason matching Status.
+	Reason DisconnectReason
+}
+
+// Error ...
+func (err LoginFailedError) Error() string {
+	return fmt.Sprintf("login failed with play status %v: %v", err.Status, err.Reason)
+}
+
+// Is returns true if target is net.ErrClosed, so that errors.Is(err, net.ErrClosed) remains true for errors
+// returned because the login failed.
+func (err LoginFailedError) Is(target error) bool {
+	return target == net.ErrClosed
+}
+
+// TransferError is the error that a Conn obtained using a Dialer with a non-zero MaxTransfers is closed with
+// when the server sends a packet.Transfer, and that a Conn is closed with after calling Conn.Transfer. It is
+// returned by Conn.Err and, wrapped, by subsequent reads and writes. Dialer.FollowTransfer may be used to
+// connect to the server that the client was transferred to.
+type TransferError struct {
+	// Address is the address of the server transferred to, which is either a hostname or an IP address.
+	Address string
+	// Port is the port of the server transferred to.
+	Port uint16
+}
+
+// Error ...
+func (err TransferError) Error() string {
+	return fmt.Sprintf("transferred to %v", err.Addr())
+}
+
+// Addr returns the address and port of the server transferred to, in a form that may be passed to Dial.
+func (err TransferError) Addr() string {
+	return net.JoinHostPort(err.Address, strconv.Itoa(int(err.Port)))
+}
+
+// Is returns true if target is net.ErrClosed, so that errors.Is(err, net.ErrClosed) remains true for errors
+// returned because the connection was transferred.
+func (err TransferError) Is(target error) bool {
+	return target == net.ErrClosed
+}
+
+// LoginError is returned, wrapped in a net.OpError, by Listener.Accept for connections that were closed
+// because of an error before completing their login sequence, if ListenConfig.ReportLoginErrors is true. It
+// may be obtained using errors.As. LoginError deliberately does not implement Unwrap, so that
+// errors.Is(err, net.ErrClosed) only holds for errors returned by Accept once the Listener is closed.
+type LoginError struct {
+	// Addr is the address of the client that failed to log in.
+	Addr net.Addr
+	// Err is the error that the connection was closed with, as returned by Conn.Err.
+	Err error
+}
+
+// Error ...
+func (err *LoginError) Error() string {
+	return fmt.Sprintf("login of %v failed: %v", err.Addr, err.Err)
+}
+
+// Temporary returns true, as a LoginError does not prevent the Listener from accepting other connections.
+func (err *LoginError) Temporary() bool { return true }
+
+// Timeout returns true if the connection was closed because of a timeout, such as ErrIdleTimeout.
+func (err *LoginError) Timeout() bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err.Err, &t) && t.Timeout()
+}
+
+// loginFailed returns a LoginFailedError for the play status passed.
+func loginFailed(status int32) LoginFailedError {
+	reason, _ := DisconnectReasonFromPlayStatus(status)
+	return LoginFailedError{Status: status, Reason: reason}
+}
@@ -0,0 +1,36 @@
+package minecraft_test
+
+import (
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func ExamplePipe() {
+	// Create a client and server connection that are connected in memory. The Dialer is not authenticated,
+	// so authentication must be disabled on the listening side. Pipe returns once the client is spawned.
+	cfg, d := minecraft.ListenConfig{AuthenticationDisabled: true}, minecraft.Dialer{}
+	client, server, err := minecraft.Pipe(cfg, d, minecraft.GameData{WorldName: "Pipe"})
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	// Packets written on one end may now be read on the other.
+	if err := server.WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: "Hello!"}); err != nil {
+		panic(err)
+	}
+	for {
+		pk, err := client.ReadPacket()
+		if err != nil {
+			panic(err)
+		}
+		if text, ok := pk.(*packet.Text); ok {
+			fmt.Println(client.GameData().WorldName, text.Message)
+			return
+		}
+	}
+
+	// Output: Pipe Hello!
+}
@@ -0,0 +1,97 @@
+package minecraft
+
+import (
+	"sync"
+	"time"
+)
+
+// Flusher flushes the packets buffered by many connections using a single goroutine and ticker, instead of
+// a goroutine and ticker for every connection. A Flusher may be set to ListenConfig.Flusher and
+// Dialer.Flusher, and may be shared between multiple Listeners and Dialers.
+// Because connections are flushed one after another, a connection that blocks while flushing delays the
+// flushing of all other connections of the Flusher.
+type Flusher struct {
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
+
+	once  sync.Once
+	close chan struct{}
+}
+
+// NewFlusher creates a Flusher that flushes all connections added to it every rate. If rate is 0 or lower,
+// a default of time.Second/20 is used. The Flusher must be closed using Flusher.Close once it is no longer
+// used.
+func NewFlusher(rate time.Duration) *Flusher {
+	if rate <= 0 {
+		rate = time.Second / 20
+	}
+	f := &Flusher{conns: make(map[*Conn]struct{}), close: make(chan struct{})}
+	go f.run(rate)
+	return f
+}
+
+// Close stops the Flusher. Connections added to the Flusher are no longer flushed automatically, but are
+// not closed.
+func (f *Flusher) Close() error {
+	f.once.Do(func() {
+		close(f.close)
+	})
+	return nil
+}
+
+// add adds a Conn to the Flusher so that it is flushed on every tick. The Conn is removed once it is closed.
+func (f *Flusher) add(conn *Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conns[conn] = struct{}{}
+}
+
+// run flushes all connections of the Flusher every rate until the Flusher is closed.
+func (f *Flusher) run(rate time.Duration) {
+	ticker := time.NewTicker(rate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.close:
+			return
+		case <-ticker.C:
+			f.flush()
+		}
+	}
+}
+
+// flush flushes all connections of the Flusher. Connections that are closed, or that fail to flush and are
+// closed as a result, are removed from the Flusher. The connections are flushed without holding f.mu, so that
+// adding a connection never waits for a flush.
+func (f *Flusher) flush() {
+	f.mu.Lock()
+	conns := make([]*Conn, 0, len(f.conns))
+	for conn := range f.conns {
+		conns = append(conns, conn)
+	}
+	f.mu.Unlock()
+
+	var removed []*Conn
+	for _, conn := range conns {
+		select {
+		case <-conn.ctx.Done():
+			removed = append(removed, conn)
+			continue
+		default:
+		}
+		if err := conn.Flush(); err != nil {
+			// Closing a connection may block while the packets left are flushed, so it is done in a separate
+			// goroutine to not delay the flushing of other connections.
+			go conn.close(err)
+			removed = append(removed, conn)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range removed {
+		delete(f.conns, conn)
+	}
+}
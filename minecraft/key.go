@@ -0,0 +1,7 @@
+//go:build !gophertunnel_debugkey
+
+package minecraft
+
+// storeEncryptionKey is a no-op: The key used to encrypt a connection is only stored in builds with the
+// gophertunnel_debugkey build tag.
+func (conn *Conn) storeEncryptionKey([32]byte) {}
@@ -0,0 +1,23 @@
+//go:build gophertunnel_debugkey
+
+package minecraft
+
+import "slices"
+
+// EncryptionKey returns a copy of the key used to encrypt the packets sent over the Conn, or nil if encryption
+// was not enabled. The key may be used by external tools to decrypt a capture of the connection for debugging.
+// EncryptionKey is only available in builds with the gophertunnel_debugkey build tag, so that the key of a
+// connection is never accidentally exposed in production. It should never be used outside debugging.
+func (conn *Conn) EncryptionKey() []byte {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	return slices.Clone(conn.encryptionKey)
+}
+
+// storeEncryptionKey stores the key used to encrypt the connection so that it may be returned by
+// EncryptionKey.
+func (conn *Conn) storeEncryptionKey(key [32]byte) {
+	conn.sendMu.Lock()
+	defer conn.sendMu.Unlock()
+	conn.encryptionKey = key[:]
+}
@@ -0,0 +1,58 @@
+package minecraft_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"testing"
+)
+
+// BenchmarkPipeLogin compares logging in with a key generated for every Listener and Dialer with logging in
+// using keys set in the ListenConfig and Dialer, as during a login storm. The CPU time spent per login is
+// reported.
+func BenchmarkPipeLogin(b *testing.B) {
+	b.Run("GeneratedKeys", func(b *testing.B) {
+		benchmarkPipeLogin(b, minecraft.ListenConfig{AuthenticationDisabled: true}, minecraft.Dialer{})
+	})
+	b.Run("SharedKeys", func(b *testing.B) {
+		cfg := minecraft.ListenConfig{AuthenticationDisabled: true, PrivateKey: generateKey(b)}
+		benchmarkPipeLogin(b, cfg, minecraft.Dialer{PrivateKey: generateKey(b)})
+	})
+}
+
+// benchmarkPipeLogin logs a client in to a server in memory once per iteration using the ListenConfig and
+// Dialer passed.
+func benchmarkPipeLogin(b *testing.B, cfg minecraft.ListenConfig, d minecraft.Dialer) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := cpuSeconds()
+	for i := 0; i < b.N; i++ {
+		client, server, err := minecraft.Pipe(cfg, d, minecraft.GameData{})
+		if err != nil {
+			b.Fatalf("pipe: %v", err)
+		}
+		_ = client.Close()
+		_ = server.Close()
+	}
+	b.StopTimer()
+	b.ReportMetric((cpuSeconds()-start)*1e9/float64(b.N), "cpu-ns/op")
+}
+
+// BenchmarkGenerateKey measures generating a single P-384 key, which a Listener and Dialer do if no
+// PrivateKey is set.
+func BenchmarkGenerateKey(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		generateKey(b)
+	}
+}
+
+// generateKey generates a P-384 private key.
+func generateKey(b *testing.B) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		b.Fatalf("generate key: %v", err)
+	}
+	return key
+}
@@ -0,0 +1,109 @@
+package minecraft
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"time"
+)
+
+// pingTimeout is the duration after which a NetworkStackLatency packet sent to measure the round trip time is
+// no longer waited for.
+const pingTimeout = time.Second * 30
+
+// RoundTripTime returns the round trip time of the connection, as measured using NetworkStackLatency packets
+// if LatencyPingInterval was set in the Dialer or ListenConfig. latest is the round trip time of the last
+// packet responded to and average is a moving average of all measurements, which changes more gradually.
+// ok is false if no measurement was made yet. RoundTripTime is safe to call from multiple goroutines.
+func (conn *Conn) RoundTripTime() (latest, average time.Duration, ok bool) {
+	average = time.Duration(conn.rttAverage.Load())
+	return time.Duration(conn.rtt.Load()), average, average != 0
+}
+
+// startPinging starts sending a NetworkStackLatency packet every interval to measure the round trip time of
+// the connection.
+func (conn *Conn) startPinging(interval time.Duration) {
+	conn.pings = make(map[int64]time.Time)
+	go conn.ping(interval)
+}
+
+// ping sends a NetworkStackLatency packet every interval once the connection is spawned, until the connection
+// is closed. Packets that were not responded to within pingTimeout are forgotten.
+func (conn *Conn) ping(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !conn.loggedIn.Load() || conn.waitingForSpawn.Load() {
+				continue
+			}
+			// Timestamps are in milliseconds like those sent by vanilla, but incremented if another ping
+			// with the same timestamp is still pending.
+			conn.pingMu.Lock()
+			timestamp := now.UnixMilli()
+			for t, sent := range conn.pings {
+				if now.Sub(sent) > pingTimeout {
+					delete(conn.pings, t)
+				}
+			}
+			for _, ok := conn.pings[timestamp]; ok; _, ok = conn.pings[timestamp] {
+				timestamp++
+			}
+			conn.pings[timestamp] = now
+			conn.pingMu.Unlock()
+
+			if err := conn.WritePacket(&packet.NetworkStackLatency{Timestamp: timestamp, NeedsResponse: true}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// networkStackLatency handles a NetworkStackLatency packet held by pkData. If the packet needs a response
+// and the Conn responds to these automatically, a NetworkStackLatency with the same timestamp is written
+// back. If the packet is the response to a packet sent by ping, the round trip time is updated and true is
+// returned, so that the packet is not queued. The packet is decoded from a copy of the payload, so that it
+// may still be queued and read by the user of the Conn.
+func (conn *Conn) networkStackLatency(pkData *packetData) bool {
+	if !conn.respondToLatency && conn.pings == nil {
+		return false
+	}
+	// The packet decoded is kept in pkData, so that it is not decoded again if it is still queued.
+	pks, err := pkData.decode(conn)
+	if err != nil || len(pks) == 0 {
+		return false
+	}
+	pk, ok := pks[0].(*packet.NetworkStackLatency)
+	if !ok {
+		return false
+	}
+	if pk.NeedsResponse {
+		if conn.respondToLatency {
+			if err := conn.WritePacket(&packet.NetworkStackLatency{Timestamp: pk.Timestamp}); err != nil {
+				conn.log.Debug("respond to latency request", "error", err)
+			}
+		}
+		return false
+	}
+	if conn.pings == nil {
+		return false
+	}
+	conn.pingMu.Lock()
+	sent, ok := conn.pings[pk.Timestamp]
+	delete(conn.pings, pk.Timestamp)
+	conn.pingMu.Unlock()
+	if !ok {
+		return false
+	}
+	rtt := max(time.Since(sent), 1)
+	conn.rtt.Store(int64(rtt))
+	// The moving average is updated like the smoothed round trip time of TCP, with each new measurement
+	// weighing 1/8.
+	if average := conn.rttAverage.Load(); average == 0 {
+		conn.rttAverage.Store(int64(rtt))
+	} else {
+		conn.rttAverage.Store(average + (int64(rtt)-average)/8)
+	}
+	return true
+}
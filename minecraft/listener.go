@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"github.com/sandertv/gophertunnel/minecraft/internal"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 	"github.com/sandertv/gophertunnel/minecraft/resource"
 	"log/slog"
@@ -21,13 +22,29 @@ import (
 // ListenConfig holds settings that may be edited to change behaviour of a Listener.
 type ListenConfig struct {
 	// ErrorLog is a log.Logger that errors that occur during packet handling of
-	// clients are written to. By default, errors are not logged.
+	// clients are written to. By default, errors are not logged. Events in the
+	// lifetime of connections, such as connections being accepted, logging in,
+	// resource packs being sent and connections being closed along with the
+	// cause, are logged at debug level.
 	ErrorLog *slog.Logger
 
 	// AuthenticationDisabled specifies if authentication of players that join is disabled. If set to true, no
 	// verification will be done to ensure that the player connecting is authenticated using their XBOX Live
 	// account.
 	AuthenticationDisabled bool
+	// EncryptionDisabled specifies if encryption of connections accepted by the Listener is disabled. If set
+	// to true, no ServerToClientHandshake is sent during login and all packets, including those holding
+	// chat messages and other sensitive data, are sent over the network in plain text.
+	// WARNING: EncryptionDisabled should only ever be used for debugging purposes, for example to capture
+	// readable packets with a tool like Wireshark. It must never be set for a server exposed to the internet.
+	EncryptionDisabled bool
+
+	// EducationEdition specifies if the Listener is a Minecraft: Education Edition server. Clients whose
+	// edition does not match that of the Listener, as reported by login.ClientData.EducationEdition, fail to
+	// log in with the play status packet.PlayStatusLoginFailedVanillaEdu or
+	// packet.PlayStatusLoginFailedEduVanilla. By default, the Listener is a vanilla server, so education
+	// edition clients are rejected.
+	EducationEdition bool
 
 	// MaximumPlayers is the maximum amount of players accepted in the server. If non-zero, players that
 	// attempt to join while the server is full will be kicked during login. If zero, the maximum player count
@@ -44,6 +61,72 @@ type ListenConfig struct {
 	// allowed. If false (by default), such packets lead to the connection being closed immediately. If true,
 	// packets with too many bytes will be returned while packets with too few bytes will be skipped.
 	AllowInvalidPackets bool
+	// SendPacketViolationWarnings specifies if a packet.PacketViolationWarning should be sent to clients that
+	// send a packet that could not be decoded, like vanilla servers do. The warning holds the ID of the packet
+	// and a description of the error, which helps clients debug the invalid packet. If AllowInvalidPackets is
+	// false, the warning is sent right before the connection is closed.
+	SendPacketViolationWarnings bool
+	// RespondToLatencyRequests, if set to true, makes connections accepted by the Listener automatically
+	// respond to packet.NetworkStackLatency packets sent by the client that need a response. The packets are
+	// still returned by Conn.ReadPacket.
+	RespondToLatencyRequests bool
+	// LatencyPingInterval, if non-zero, makes connections accepted by the Listener send a
+	// packet.NetworkStackLatency to the client every LatencyPingInterval once spawned, so that the round trip
+	// time of the connection is measured. The measurements are available through Conn.RoundTripTime and
+	// Conn.Latency. The responses to these packets are not returned by Conn.ReadPacket. Vanilla clients
+	// respond to these packets, but other clients, such as those using a Dialer, might not.
+	LatencyPingInterval time.Duration
+
+	// StrictLoginSequence specifies if connections should be closed when a packet is received during login
+	// that is not expected next in the login sequence. If false (by default), such packets are deferred so
+	// that they may be read once the connection is accepted, which tolerates clients sending packets such as
+	// movement slightly too early.
+	StrictLoginSequence bool
+	// ReportLoginErrors specifies if Listener.Accept should return an error for connections that were closed
+	// because of an error before their login sequence was completed, such as connections that failed
+	// authentication or used an unsupported protocol. If true, Accept returns a net.OpError wrapping a
+	// *LoginError for every such connection. These errors are temporary, so Accept should be called again
+	// after receiving one. Login errors are buffered until Accept is called, and are dropped if the buffer
+	// is full, so that connections failing to log in never wait for Accept. If false (by default), such
+	// connections are not reported.
+	ReportLoginErrors bool
+
+	// MaxQueuedPackets is the maximum amount of packets received by a connection that may be queued while
+	// waiting to be read, for example using Conn.ReadPacket. If the maximum is reached, OverflowPolicy
+	// determines what happens to new packets. If zero, a default of 1024 is used. MaxQueuedPackets has no
+	// effect if OverflowPolicy is OverflowGrow.
+	MaxQueuedPackets int
+	// OverflowPolicy is the OverflowPolicy used by connections once MaxQueuedPackets packets are queued. By
+	// default, OverflowGrow is used, which never drops packets.
+	OverflowPolicy OverflowPolicy
+
+	// MaxBufferedBytes is the maximum total size in bytes of packets written to a connection that may be
+	// buffered while waiting to be flushed. Once reached, Write and WritePacket return an error until the
+	// buffered packets are flushed. If zero, a default of 64 MiB is used.
+	MaxBufferedBytes int
+	// MaxWriteBatchSize is the maximum size in bytes, before compression, of a batch of packets written to
+	// a connection. When flushing, the packets buffered are split into multiple batches that each stay below
+	// this size, which may help with transports that handle large messages poorly. A single packet larger
+	// than the maximum is still sent in a batch of its own. If zero, all packets are sent in a single batch.
+	MaxWriteBatchSize int
+
+	// MaxPacketsPerSecond is the maximum amount of packets that a connection may send in a single second after
+	// it is logged in. Packets received beyond this limit are dropped and counted in ConnStats.PacketsLimited,
+	// so that a client flooding the server with packets cannot make it spend unbounded time handling them.
+	// A client sends roughly 20 to 100 packets per second during normal play. If zero, the amount of packets
+	// is not limited.
+	MaxPacketsPerSecond int
+
+	// MaxBatchSize is the maximum size in bytes of a batch of packets sent by a client, after it is
+	// decompressed. A connection that sends a larger batch is closed. If zero, a default of 16 MiB is used.
+	// If negative, the size of batches is not limited.
+	MaxBatchSize int
+
+	// IdleTimeout is the duration after which a connection is closed if no packets were received from it.
+	// Reading from the connection then returns an error wrapping ErrIdleTimeout. Clients send packets such
+	// as PlayerAuthInput every tick once spawned, so a connection that is idle for long is likely dead.
+	// If zero, connections are never closed for being idle.
+	IdleTimeout time.Duration
 
 	// StatusProvider is the ServerStatusProvider of the Listener. When set to nil, the default provider,
 	// ListenerStatusProvider, is used as provider.
@@ -63,9 +146,18 @@ type ListenConfig struct {
 	// will not be flushed automatically. In this case, calling `(*Conn).Flush()` is required after any
 	// calls to `(*Conn).Write()` or `(*Conn).WritePacket()` to send the packets over network.
 	FlushRate time.Duration
+	// Flusher is a Flusher used to flush the packets buffered by connections accepted by the Listener. If
+	// set, FlushRate is ignored and connections are flushed by the goroutine of the Flusher rather than by a
+	// goroutine of their own, which scales better to many connections.
+	Flusher *Flusher
 
 	// ResourcePacks is a slice of resource packs that the listener may hold. Each client will be asked to
-	// download these resource packs upon joining.
+	// download these resource packs upon joining. The packs are sent to clients in the order of the slice,
+	// both in the ResourcePacksInfo and in the ResourcePackStack, so that the order in which packs override
+	// each other is deterministic. resource.Pack.WithStack may be used to choose the list of the stack that a
+	// pack is placed in. Packs are moved after the packs they depend on, as found in resource.Pack.Dependencies,
+	// so that dependencies are loaded before their dependents. Listen fails if the packs depend on each other
+	// in a cycle.
 	// Use Listener.AddResourcePack() to add a resource pack and Listener.RemoveResourcePack() to remove a resource pack
 	// after having called ListenConfig.Listen(). Note that these methods will not update resource packs for active connections.
 	ResourcePacks []*resource.Pack
@@ -73,14 +165,53 @@ type ListenConfig struct {
 	// to render the world more effectively. If these are nil, the default biome definitions will be used.
 	Biomes map[string]any
 	// TexturePacksRequired specifies if clients that join must accept the texture pack in order for them to
-	// be able to join the server. If they don't accept, they can only leave the server.
+	// be able to join the server. If they don't accept, they can only leave the server. The protocol holds
+	// only a single flag for all packs, so packs cannot be required individually.
 	TexturePacksRequired bool
+	// ResourcePackChunkSize is the size in bytes of the chunks in which resource packs are sent to clients
+	// that download them. Smaller chunks recover faster on lossy connections, while larger chunks reduce
+	// overhead on fast connections. The chunk size is clamped between 4 kB and 2 MB. If zero, a default of
+	// 128 kB is used.
+	ResourcePackChunkSize int
+	// ResourcePackStatusFunc is called when a client accepted by the Listener reaches a step in the resource
+	// pack sequence, which may be used to track how many clients finish downloading resource packs and how
+	// long it takes them. response is packet.PackResponseAllPacksDownloaded once the client has all packs,
+	// packet.PackResponseCompleted once it finished the sequence or packet.PackResponseRefused if it
+	// refused the packs. d is the time passed since the ResourcePacksInfo packet starting the sequence was
+	// sent. The function is called in a separate goroutine, so it does not block the reading of packets.
+	ResourcePackStatusFunc func(conn *Conn, response byte, d time.Duration)
+
+	// PrivateKey is the P-384 private key used to sign the handshake with clients and to derive the keys used
+	// to encrypt connections. If nil, a new key is generated when the Listener is created, which is then
+	// used for all connections accepted by it. PrivateKey may be set to share a single key between multiple
+	// Listeners.
+	PrivateKey *ecdsa.PrivateKey
+
+	// CustomPackets is a packet.Pool holding packets that are added to the packet pool of every connection
+	// accepted by the Listener, on top of the packets of the Protocol used by the connection. Packets read
+	// with an ID registered in CustomPackets are returned as the packet produced by its function rather than
+	// as a *packet.Unknown. Packets registered using packet.RegisterPacketFromClient apply to all Listeners
+	// instead.
+	CustomPackets packet.Pool
+
+	// VerificationCache, if not nil, caches the tokens in login chains of connections accepted by the Listener
+	// that were verified successfully, so that their signatures are not verified again when the same account
+	// logs in repeatedly. It may be set to share a single cache between multiple Listeners.
+	VerificationCache *login.VerificationCache
 
 	// PacketFunc is called whenever a packet is read from or written to a connection returned when using
 	// Listener.Accept. It includes packets that are otherwise covered in the connection sequence, such as the
 	// Login packet. The function is called with the header of the packet and its raw payload, the address
 	// from which the packet originated, and the destination address.
 	PacketFunc func(header packet.Header, payload []byte, src, dst net.Addr)
+
+	// WrapConn, if set, is called with every net.Conn accepted by the network listened on, before anything is
+	// read from it. The net.Conn returned is used for the connection instead, which allows adding a layer such
+	// as TLS to the transport, for example by returning
+	// minecraft.NewFramedConn(tls.Server(minecraft.NewStreamConn(conn), tlsConfig)).
+	// The same requirements as for Dialer.WrapConn apply. WrapConn is called from the goroutine accepting
+	// connections, so it should not block. If it returns an error, the accepted net.Conn is closed.
+	WrapConn func(conn net.Conn) (net.Conn, error)
 }
 
 // Listener implements a Minecraft listener on top of an unspecific net.Listener. It abstracts away the
@@ -97,8 +228,9 @@ type Listener struct {
 	// to the playerCount, no more players will be accepted.
 	playerCount atomic.Int32
 
-	incoming chan *Conn
-	close    chan struct{}
+	incoming    chan *Conn
+	loginErrors chan *LoginError
+	close       chan struct{}
 
 	key *ecdsa.PrivateKey
 }
@@ -121,6 +253,11 @@ func (cfg ListenConfig) Listen(network string, address string) (*Listener, error
 		cfg.FlushRate = time.Second / 20
 	}
 
+	packs, err := resource.SortByDependencies(cfg.ResourcePacks)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
 	n, ok := networkByID(network, cfg.ErrorLog)
 	if !ok {
 		return nil, fmt.Errorf("listen: no network under id %v", network)
@@ -130,17 +267,24 @@ func (cfg ListenConfig) Listen(network string, address string) (*Listener, error
 	if err != nil {
 		return nil, err
 	}
-	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("generating ECDSA key: %w", err)
+	key := cfg.PrivateKey
+	if key == nil {
+		if key, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+			_ = netListener.Close()
+			return nil, fmt.Errorf("generating ECDSA key: %w", err)
+		}
+	} else if key.Curve != elliptic.P384() {
+		_ = netListener.Close()
+		return nil, fmt.Errorf("private key: curve %v is not P-384", key.Curve.Params().Name)
 	}
 	listener := &Listener{
-		cfg:      cfg,
-		listener: netListener,
-		packs:    slices.Clone(cfg.ResourcePacks),
-		incoming: make(chan *Conn),
-		close:    make(chan struct{}),
-		key:      key,
+		cfg:         cfg,
+		listener:    netListener,
+		packs:       packs,
+		incoming:    make(chan *Conn),
+		loginErrors: make(chan *LoginError, loginErrorBuffer),
+		close:       make(chan struct{}),
+		key:         key,
 	}
 
 	// Actually start listening.
@@ -162,13 +306,19 @@ func Listen(network, address string) (*Listener, error) {
 // Accept accepts a fully connected (on Minecraft layer) connection which is ready to receive and send
 // packets. It is recommended to cast the net.Conn returned to a *minecraft.Conn so that it is possible to
 // use the Conn.ReadPacket() and Conn.WritePacket() methods.
-// Accept returns an error if the listener is closed.
+// Accept returns an error if the listener is closed. If ListenConfig.ReportLoginErrors is true, Accept also
+// returns an error wrapping a *LoginError for connections that failed to log in. Such an error is not fatal:
+// it may be detected using errors.As, after which Accept should be called again.
 func (listener *Listener) Accept() (net.Conn, error) {
-	conn, ok := <-listener.incoming
-	if !ok {
-		return nil, &net.OpError{Op: "accept", Net: "minecraft", Addr: listener.Addr(), Err: net.ErrClosed}
+	select {
+	case conn, ok := <-listener.incoming:
+		if !ok {
+			return nil, &net.OpError{Op: "accept", Net: "minecraft", Addr: listener.Addr(), Err: net.ErrClosed}
+		}
+		return conn, nil
+	case err := <-listener.loginErrors:
+		return nil, &net.OpError{Op: "accept", Net: "minecraft", Addr: listener.Addr(), Err: err}
 	}
-	return conn, nil
 }
 
 // Disconnect disconnects a Minecraft Conn passed by first sending a disconnect with the message passed, and
@@ -182,12 +332,29 @@ func (listener *Listener) Disconnect(conn *Conn, message string) error {
 	return conn.close(conn.closeErr(message))
 }
 
-// AddResourcePack adds a new resource pack to the listener's resource packs.
+// AddResourcePack adds a new resource pack to the listener's resource packs. The pack is placed after the
+// packs it depends on, or before the packs that depend on it. If adding the pack results in a dependency
+// cycle, the pack is not added and the error is logged to the ErrorLog of the ListenConfig. Use
+// TryAddResourcePack to handle this error instead.
 // Note: This method will not update resource packs for active connections.
 func (listener *Listener) AddResourcePack(pack *resource.Pack) {
+	if err := listener.TryAddResourcePack(pack); err != nil {
+		listener.cfg.ErrorLog.Error(err.Error(), "pack", pack.UUID())
+	}
+}
+
+// TryAddResourcePack adds a new resource pack to the listener's resource packs like AddResourcePack. If adding
+// the pack results in a dependency cycle, an error is returned and the pack is not added.
+// Note: This method will not update resource packs for active connections.
+func (listener *Listener) TryAddResourcePack(pack *resource.Pack) error {
 	listener.packsMu.Lock()
 	defer listener.packsMu.Unlock()
-	listener.packs = append(listener.packs, pack)
+	sorted, err := resource.SortByDependencies(append(slices.Clip(listener.packs), pack))
+	if err != nil {
+		return fmt.Errorf("add resource pack: %w", err)
+	}
+	listener.packs = sorted
+	return nil
 }
 
 // RemoveResourcePack removes a resource pack from the listener's configuration by its UUID.
@@ -214,9 +381,14 @@ func (listener *Listener) Close() error {
 // server name of the listener, provided the listener isn't currently hijacking the pong of another server.
 func (listener *Listener) updatePongData() {
 	s := listener.status()
+	var port int
+	if addr, ok := listener.Addr().(*net.UDPAddr); ok {
+		// Networks other than RakNet, such as the pipe network, might not listen on a UDP address.
+		port = addr.Port
+	}
 	listener.listener.PongData([]byte(fmt.Sprintf("MCPE;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;%v;",
 		s.ServerName, protocol.CurrentProtocol, protocol.CurrentVersion, s.PlayerCount, s.MaxPlayers,
-		listener.listener.ID(), s.ServerSubName, "Creative", 1, listener.Addr().(*net.UDPAddr).Port, listener.Addr().(*net.UDPAddr).Port, 0,
+		listener.listener.ID(), s.ServerSubName, "Creative", 1, port, port, 0,
 	)))
 }
 
@@ -248,6 +420,15 @@ func (listener *Listener) listen() {
 			// close too.
 			return
 		}
+		if listener.cfg.WrapConn != nil {
+			wrapped, err := listener.cfg.WrapConn(netConn)
+			if err != nil {
+				listener.cfg.ErrorLog.Error("wrap connection: "+err.Error(), "raddr", netConn.RemoteAddr())
+				_ = netConn.Close()
+				continue
+			}
+			netConn = wrapped
+		}
 		listener.createConn(netConn)
 	}
 }
@@ -259,32 +440,74 @@ func (listener *Listener) createConn(netConn net.Conn) {
 	packs := slices.Clone(listener.packs)
 	listener.packsMu.RUnlock()
 
-	conn := newConn(netConn, listener.key, listener.cfg.ErrorLog, proto{}, listener.cfg.FlushRate, true)
+	flushRate := listener.cfg.FlushRate
+	if listener.cfg.Flusher != nil {
+		flushRate = -1
+	}
+	conn := newConn(netConn, listener.key, listener.cfg.ErrorLog, proto{}, flushRate, true)
+	conn.server = true
+	if listener.cfg.Flusher != nil {
+		listener.cfg.Flusher.add(conn)
+	}
 	conn.acceptedProto = append(listener.cfg.AcceptedProtocols, proto{})
 	conn.compression = listener.cfg.Compression
-	conn.pool = conn.proto.Packets(true)
+	conn.customPackets = listener.cfg.CustomPackets
+	conn.verificationCache = listener.cfg.VerificationCache
+	conn.pool = conn.packetPool(true)
 
 	conn.packetFunc = listener.cfg.PacketFunc
 	conn.texturePacksRequired = listener.cfg.TexturePacksRequired
+	conn.packChunkSize = packChunkSize(listener.cfg.ResourcePackChunkSize)
+	conn.packStatusFunc = listener.cfg.ResourcePackStatusFunc
 	conn.resourcePacks = packs
 	conn.biomes = listener.cfg.Biomes
 	conn.gameData.WorldName = listener.status().ServerName
 	conn.authEnabled = !listener.cfg.AuthenticationDisabled
+	conn.encryptionDisabled = listener.cfg.EncryptionDisabled
+	conn.educationEdition = listener.cfg.EducationEdition
 	conn.disconnectOnUnknownPacket = !listener.cfg.AllowUnknownPackets
 	conn.disconnectOnInvalidPacket = !listener.cfg.AllowInvalidPackets
+	conn.packetViolationWarnings = listener.cfg.SendPacketViolationWarnings
+	conn.respondToLatency = listener.cfg.RespondToLatencyRequests
+	if listener.cfg.LatencyPingInterval > 0 {
+		conn.startPinging(listener.cfg.LatencyPingInterval)
+	}
+	conn.strictLoginSequence = listener.cfg.StrictLoginSequence
+	conn.setOverflowPolicy(listener.cfg.OverflowPolicy, listener.cfg.MaxQueuedPackets)
+	conn.maxPacketsPerSecond = listener.cfg.MaxPacketsPerSecond
+	if listener.cfg.MaxBufferedBytes > 0 {
+		conn.maxBufferedBytes = listener.cfg.MaxBufferedBytes
+	}
+	conn.enc.SetMaxBatchBytes(listener.cfg.MaxWriteBatchSize)
+	switch {
+	case listener.cfg.MaxBatchSize == 0:
+		conn.dec.SetMaxBatchSize(defaultMaxBatchSize)
+	case listener.cfg.MaxBatchSize > 0:
+		conn.dec.SetMaxBatchSize(listener.cfg.MaxBatchSize)
+	}
 
 	if listener.playerCount.Load() == int32(listener.cfg.MaximumPlayers) && listener.cfg.MaximumPlayers != 0 {
 		// The server was full. We kick the player immediately and close the connection.
-		_ = conn.WritePacket(&packet.PlayStatus{Status: packet.PlayStatusLoginFailedServerFull})
-		_ = conn.close(conn.closeErr("server full"))
+		_ = conn.FailLogin(packet.PlayStatusLoginFailedServerFull)
+		listener.reportLoginError(conn)
 		return
 	}
 	listener.playerCount.Add(1)
 	listener.updatePongData()
+	conn.log.Debug("accepted connection")
+
+	if listener.cfg.IdleTimeout > 0 {
+		go conn.closeIdle(listener.cfg.IdleTimeout)
+	}
 
 	go listener.handleConn(conn)
 }
 
+// defaultMaxBatchSize is the maximum size of a batch sent by a client after decompression if
+// ListenConfig.MaxBatchSize is not set. Clients generally only send small batches, so this leaves a lot of
+// headroom.
+const defaultMaxBatchSize = 16 * 1024 * 1024
+
 // status returns the current ServerStatus of the Listener.
 func (listener *Listener) status() ServerStatus {
 	status := listener.cfg.StatusProvider.ServerStatus(int(listener.playerCount.Load()), listener.cfg.MaximumPlayers)
@@ -309,16 +532,23 @@ func (listener *Listener) handleConn(conn *Conn) {
 		if err != nil {
 			if !errors.Is(err, net.ErrClosed) {
 				conn.log.Error(err.Error())
+				// Close the connection with the error as cause so that it is returned by subsequent calls
+				// to methods such as ReadPacket.
+				_ = conn.close(err)
 			}
+			listener.reportLoginError(conn)
 			return
 		}
-		for _, data := range packets {
-			loggedInBefore := conn.loggedIn
-			if err := conn.receive(data); err != nil {
+		conn.stats.batchesRead.Add(1)
+		for i, data := range packets {
+			loggedInBefore := conn.loggedIn.Load()
+			if err := conn.receive(data, i == len(packets)-1); err != nil {
 				conn.log.Error(err.Error())
+				_ = conn.close(err)
+				listener.reportLoginError(conn)
 				return
 			}
-			if !loggedInBefore && conn.loggedIn {
+			if !loggedInBefore && conn.loggedIn.Load() {
 				select {
 				case <-listener.close:
 					// The listener was closed while this one was logged in, so the incoming channel will be
@@ -333,3 +563,24 @@ func (listener *Listener) handleConn(conn *Conn) {
 		}
 	}
 }
+
+// reportLoginError passes a LoginError to Accept if ListenConfig.ReportLoginErrors is true and the Conn
+// passed was closed with an error before its login sequence was completed. reportLoginError never blocks:
+// if loginErrorBuffer errors are already waiting for Accept, the error is dropped.
+func (listener *Listener) reportLoginError(conn *Conn) {
+	if !listener.cfg.ReportLoginErrors || conn.loggedIn.Load() {
+		return
+	}
+	cause := conn.Err()
+	if cause == nil {
+		return
+	}
+	select {
+	case listener.loginErrors <- &LoginError{Addr: conn.RemoteAddr(), Err: cause}:
+	default:
+		conn.log.Debug("login error dropped: too many errors waiting for Accept", "error", cause)
+	}
+}
+
+// loginErrorBuffer is the maximum amount of LoginErrors waiting to be returned by Listener.Accept.
+const loginErrorBuffer = 64
@@ -0,0 +1,93 @@
+package minecraft_test
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"net"
+	"testing"
+)
+
+// TestListenerReportLoginErrors checks that connections failing to log in do not wait for Accept to be
+// called, and that Accept returns a temporary error wrapping a *LoginError for each of them afterwards.
+func TestListenerReportLoginErrors(t *testing.T) {
+	const clients = 3
+	l, err := minecraft.ListenConfig{ReportLoginErrors: true}.Listen("pipe", "127.0.0.1:65000")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	// Authentication is required by the Listener, so each of the clients fails to log in.
+	for i := 0; i < clients; i++ {
+		if conn, err := (minecraft.Dialer{}).Dial("pipe", "127.0.0.1:65000"); err == nil {
+			_ = conn.Close()
+			t.Fatalf("dial %v: expected an error logging in without authentication", i)
+		}
+	}
+	for i := 0; i < clients; i++ {
+		_, err := l.Accept()
+		var loginErr *minecraft.LoginError
+		if !errors.As(err, &loginErr) {
+			t.Fatalf("accept %v: expected an error wrapping a *LoginError, got %v", i, err)
+		}
+		if !loginErr.Temporary() || errors.Is(err, net.ErrClosed) {
+			t.Errorf("accept %v: expected a temporary error, got %v", i, err)
+		}
+	}
+	_ = l.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("accept after close: expected %v, got %v", net.ErrClosed, err)
+	}
+}
+
+// TestListenerAddResourcePackCycle checks that TryAddResourcePack returns an error and AddResourcePack drops
+// the pack if it depends on a pack that depends on it, while a pack without a cycle is added and sent to
+// clients.
+func TestListenerAddResourcePackCycle(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	cfg := minecraft.ListenConfig{
+		AuthenticationDisabled: true,
+		ResourcePacks:          []*resource.Pack{testPackWithDependencies(t, b, 100, a)},
+	}
+	l, err := cfg.Listen("pipe", "127.0.0.1:65001")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	if err := l.TryAddResourcePack(testPackWithDependencies(t, a, 100, b)); err == nil {
+		t.Errorf("add pack with dependency cycle: expected an error")
+	}
+	// AddResourcePack drops a pack that results in a cycle, so it is not sent to clients either.
+	l.AddResourcePack(testPackWithDependencies(t, a, 100, b))
+	if err := l.TryAddResourcePack(testPackWithDependencies(t, a, 100)); err != nil {
+		t.Fatalf("add pack: %v", err)
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+		_ = conn.(*minecraft.Conn).StartGame(minecraft.GameData{})
+	}()
+	client, err := minecraft.Dialer{}.Dial("pipe", "127.0.0.1:65001")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	if err := client.DoSpawn(); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if server, ok := <-accepted; ok {
+		defer server.Close()
+	}
+	if packs := client.ResourcePacks(); len(packs) != 2 {
+		t.Errorf("client resource packs: expected 2 packs, got %v", packs)
+	}
+}
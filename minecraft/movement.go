@@ -0,0 +1,145 @@
+package minecraft
+
+import (
+	"context"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"math"
+	"sync"
+	"time"
+)
+
+// MovementController moves the player of a Conn obtained using a Dialer by sending a packet.PlayerAuthInput
+// every tick, as servers with server authoritative movement require. It keeps track of the position and
+// rotation of the player and of the tick counter that the server uses to match inputs with its own
+// simulation.
+// Positions used by the MovementController are those of the eyes of the player, like the position in
+// GameData.PlayerPosition and in packet.MovePlayer. The MovementController does not simulate physics such as
+// gravity and collisions, so it is suited for moving over flat ground or while flying. Servers may correct the
+// position of the player by sending a packet.MovePlayer or packet.CorrectPlayerMovePrediction, in which case
+// Teleport and SetTick should be called with the position and tick of the packet to stay in sync.
+// A MovementController is safe for concurrent use.
+type MovementController struct {
+	conn *Conn
+
+	mu         sync.Mutex
+	tick       uint64
+	pos        mgl32.Vec3
+	pitch, yaw float32
+	target     mgl32.Vec3
+	speed      float32
+	moving     bool
+}
+
+// NewMovementController creates a MovementController for the Conn passed. The position and rotation of the
+// player are initialised using the GameData of the Conn, and the tick counter starts at 0.
+func NewMovementController(conn *Conn) *MovementController {
+	data := conn.GameData()
+	return &MovementController{conn: conn, pos: data.PlayerPosition, pitch: data.Pitch, yaw: data.Yaw}
+}
+
+// Position returns the current position of the eyes of the player.
+func (m *MovementController) Position() mgl32.Vec3 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pos
+}
+
+// Tick returns the tick that will be sent in the next packet.PlayerAuthInput.
+func (m *MovementController) Tick() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tick
+}
+
+// SetTick sets the tick that will be sent in the next packet.PlayerAuthInput. It should be called when the
+// server sends a packet.CorrectPlayerMovePrediction, using the tick held in the packet.
+func (m *MovementController) SetTick(tick uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tick = tick
+}
+
+// Teleport sets the position of the player to pos immediately and stops any movement started using MoveTo. It
+// should be called when the server moves the player, for example through a packet.MovePlayer.
+func (m *MovementController) Teleport(pos mgl32.Vec3) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pos, m.moving = pos, false
+}
+
+// Look sets the rotation of the player. Yaw and pitch are measured in degrees.
+func (m *MovementController) Look(yaw, pitch float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.yaw, m.pitch = yaw, pitch
+}
+
+// MoveTo makes the player move towards the target position passed with a speed in blocks per tick, such as
+// 0.2 for a walking player. The player turns to face the target and moves in a straight line until it reaches
+// the target, which happens over the following calls to Step.
+func (m *MovementController) MoveTo(target mgl32.Vec3, speed float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.target, m.speed, m.moving = target, speed, true
+}
+
+// Moving checks if the player is currently moving towards a target passed to MoveTo.
+func (m *MovementController) Moving() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.moving
+}
+
+// Step advances the movement of the player by one tick and writes the resulting packet.PlayerAuthInput to the
+// Conn. Step should be called once every tick, that is, every 50 milliseconds, or Run should be used instead.
+func (m *MovementController) Step() error {
+	m.mu.Lock()
+	pk := &packet.PlayerAuthInput{
+		InputData:        protocol.NewBitset(packet.PlayerAuthInputBitsetSize),
+		InputMode:        packet.InputModeMouse,
+		PlayMode:         packet.PlayModeNormal,
+		InteractionModel: packet.InteractionModelCrosshair,
+		Tick:             m.tick,
+	}
+	if m.moving {
+		diff := m.target.Sub(m.pos)
+		if dist := diff.Len(); dist <= m.speed || m.speed <= 0 {
+			pk.Delta, m.pos, m.moving = diff, m.target, false
+		} else {
+			pk.Delta = diff.Mul(m.speed / dist)
+			m.pos = m.pos.Add(pk.Delta)
+		}
+		if diff[0] != 0 || diff[2] != 0 {
+			// A yaw of 0 faces the positive Z axis and a yaw of 90 the negative X axis.
+			m.yaw = float32(-math.Atan2(float64(diff[0]), float64(diff[2])) * 180 / math.Pi)
+			pk.MoveVector, pk.RawMoveVector, pk.AnalogueMoveVector = mgl32.Vec2{0, 1}, mgl32.Vec2{0, 1}, mgl32.Vec2{0, 1}
+			pk.InputData.Set(packet.InputFlagUp)
+		}
+	}
+	pk.Position, pk.Pitch, pk.Yaw, pk.HeadYaw = m.pos, m.pitch, m.yaw, m.yaw
+	m.tick++
+	m.mu.Unlock()
+
+	return m.conn.WritePacket(pk)
+}
+
+// Run calls Step every tick until ctx is done or the Conn is closed. It returns the error returned by Step, or
+// an error if ctx is done or the Conn is closed.
+func (m *MovementController) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second / 20)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return m.conn.wrap(ctx.Err(), "run movement")
+		case <-m.conn.ctx.Done():
+			return m.conn.closeErr("run movement")
+		case <-ticker.C:
+			if err := m.Step(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -217,6 +217,9 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 		if err != nil {
 			return err
 		}
+		if err := d.checkLength(length, "ByteArray"); err != nil {
+			return err
+		}
 		b := make([]byte, length)
 		if _, err := d.r.Read(b); err != nil {
 			return BufferOverrunError{Op: "ByteArray"}
@@ -297,6 +300,9 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 			if err != nil {
 				return BufferOverrunError{Op: "ByteSlice"}
 			}
+			if err := d.checkLength(length, "ByteSlice"); err != nil {
+				return err
+			}
 			if length == 0 {
 				// Empty lists are allowed to have the TAG_Byte type.
 				val.Set(reflect.MakeSlice(sliceType, int(length), int(length)))
@@ -456,6 +462,16 @@ func (d *Decoder) populateFields(val reflect.Value, m map[string]reflect.Value)
 	}
 }
 
+// checkLength checks if the length of a byte array or list read for the op passed is valid. Lengths may not
+// be negative, and may not exceed the amount of bytes that may be read with the NetworkLittleEndian format,
+// so that no large amounts of memory are allocated for data that is not present.
+func (d *Decoder) checkLength(length int32, op string) error {
+	if length < 0 || (length > maximumNetworkOffset && d.Encoding == NetworkLittleEndian) {
+		return InvalidLengthError{Off: d.r.off, Op: op, Length: length}
+	}
+	return nil
+}
+
 // tag reads a tag from the decoder, and its name if the tag type is not a TAG_End.
 func (d *Decoder) tag() (t tagType, tagName string, err error) {
 	if d.depth >= maximumNestingDepth {
@@ -191,6 +191,10 @@ func (e networkLittleEndian) Int32Slice(r *offsetReader) ([]int32, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int32Slice"}
 	}
+	// Every element takes up at least one byte, so the length can never exceed the bytes that may be read.
+	if n < 0 || n > maximumNetworkOffset {
+		return nil, InvalidLengthError{Off: r.off, Op: "Int32Slice", Length: n}
+	}
 	m := make([]int32, n)
 	for i := int32(0); i < n; i++ {
 		m[i], err = e.Int32(r)
@@ -207,6 +211,10 @@ func (e networkLittleEndian) Int64Slice(r *offsetReader) ([]int64, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int64Slice"}
 	}
+	// Every element takes up at least one byte, so the length can never exceed the bytes that may be read.
+	if n < 0 || n > maximumNetworkOffset {
+		return nil, InvalidLengthError{Off: r.off, Op: "Int64Slice", Length: n}
+	}
 	m := make([]int64, n)
 	for i := int32(0); i < n; i++ {
 		m[i], err = e.Int64(r)
@@ -157,6 +157,19 @@ func (err MaximumBytesReadError) Error() string {
 	return fmt.Sprintf("nbt: limit of bytes read %v with NetworkLittleEndian format exhausted", maximumNetworkOffset)
 }
 
+// InvalidLengthError is returned if the length of an array or list read is negative, or if it exceeds the
+// maximum amount of bytes that may be read with the NetworkLittleEndian format.
+type InvalidLengthError struct {
+	Off    int64
+	Op     string
+	Length int32
+}
+
+// Error ...
+func (err InvalidLengthError) Error() string {
+	return fmt.Sprintf("nbt: invalid length %v at offset %v during op '%v'", err.Length, err.Off, err.Op)
+}
+
 // InvalidVarintError is returned if a varint(32/64) is encountered that does
 // not end after 5 or 10 bytes respectively.
 type InvalidVarintError struct {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"strings"
 )
 
 // packetData holds the data of a Minecraft packet.
@@ -12,6 +13,15 @@ type packetData struct {
 	h       *packet.Header
 	full    []byte
 	payload *bytes.Buffer
+	// batchEnd specifies if the packet was the last packet in the batch that it was received in.
+	batchEnd bool
+
+	// decoded specifies if the payload was already decoded, in which case pks and err hold the result.
+	// Packets may be decoded before being queued, such as to check if they hold the output of a command, so
+	// the result is kept to decode the payload, and report errors decoding it, only once.
+	decoded bool
+	pks     []packet.Packet
+	err     error
 }
 
 // parseData parses the packet data slice passed into a packetData struct.
@@ -38,8 +48,18 @@ func (err unknownPacketError) Error() string {
 	return fmt.Sprintf("unexpected packet (ID=%v)", err.id)
 }
 
-// decode decodes the packet payload held in the packetData and returns the packet.Packet decoded.
-func (p *packetData) decode(conn *Conn) (pks []packet.Packet, err error) {
+// decode decodes the packet payload held in the packetData and returns the packet.Packet decoded. The payload
+// is only decoded the first time decode is called. Later calls return the same result.
+func (p *packetData) decode(conn *Conn) ([]packet.Packet, error) {
+	if !p.decoded {
+		p.pks, p.err = p.decodePayload(conn)
+		p.decoded = true
+	}
+	return p.pks, p.err
+}
+
+// decodePayload decodes the packet payload held in the packetData and returns the packet.Packet decoded.
+func (p *packetData) decodePayload(conn *Conn) (pks []packet.Packet, err error) {
 	// Attempt to fetch the packet with the right packet ID from the pool.
 	pkFunc, ok := conn.pool[p.h.PacketID]
 	var pk packet.Packet
@@ -54,22 +74,64 @@ func (p *packetData) decode(conn *Conn) (pks []packet.Packet, err error) {
 		pk = pkFunc()
 	}
 
+	size := p.payload.Len()
 	defer func() {
 		if recoveredErr := recover(); recoveredErr != nil {
-			err = fmt.Errorf("decode packet %T: %w", pk, recoveredErr.(error))
+			if err, _ = recoveredErr.(error); err == nil {
+				err = fmt.Errorf("%v", recoveredErr)
+			}
 		}
-		if err != nil && !errors.Is(err, unknownPacketError{}) && conn.disconnectOnInvalidPacket {
-			_ = conn.Close()
+		if err == nil || errors.As(err, new(unknownPacketError)) {
+			return
+		}
+		err = InvalidPacketError{
+			PacketID: p.h.PacketID,
+			Type:     fmt.Sprintf("%T", pk),
+			Offset:   size - p.payload.Len(),
+			Size:     size,
+			Err:      err,
+		}
+		if conn.packetViolationWarnings {
+			conn.warnViolation(p.h.PacketID, err)
+		}
+		if conn.disconnectOnInvalidPacket {
+			_ = conn.close(err)
 		}
 	}()
 
 	r := conn.proto.NewReader(p.payload, conn.shieldID.Load(), conn.readerLimits)
 	pk.Marshal(r)
 	if p.payload.Len() != 0 {
-		err = fmt.Errorf("decode packet %T: %v unread bytes left: 0x%x", pk, p.payload.Len(), p.payload.Bytes())
+		err = fmt.Errorf("%v unread bytes left: 0x%x", p.payload.Len(), p.payload.Bytes())
 	}
 	if conn.disconnectOnInvalidPacket && err != nil {
 		return nil, err
 	}
 	return conn.proto.ConvertToLatest(pk, conn), err
 }
+
+// maxViolationContext is the maximum length in bytes of the context of a PacketViolationWarning sent by
+// warnViolation. Errors of packets that could not be decoded may hold the remaining payload of the packet, so
+// the context is cut off to not echo large amounts of data back to the other end.
+const maxViolationContext = 128
+
+// warnViolation sends a PacketViolationWarning to the other end of the connection for the packet with the ID
+// passed that could not be decoded due to the error passed. The severity of the warning depends on whether the
+// connection is closed as a result of the invalid packet.
+func (conn *Conn) warnViolation(id uint32, err error) {
+	severity := int32(packet.ViolationSeverityWarning)
+	if conn.disconnectOnInvalidPacket {
+		severity = packet.ViolationSeverityTerminatingConnection
+	}
+	detail := err.Error()
+	if len(detail) > maxViolationContext {
+		// The detail might be cut off in the middle of a multi-byte character, which is removed.
+		detail = strings.ToValidUTF8(detail[:maxViolationContext], "")
+	}
+	_ = conn.WritePacket(&packet.PacketViolationWarning{
+		Type:             packet.ViolationTypeMalformed,
+		Severity:         severity,
+		PacketID:         int32(id),
+		ViolationContext: detail,
+	})
+}
@@ -0,0 +1,292 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pipe creates a Minecraft connection that runs entirely in memory, using a PipeNetwork as transport. A Listener
+// is created using the ListenConfig passed and the Dialer passed connects to it. Pipe returns once the login
+// sequence, including encryption and resource packs, is completed on both ends and the client was spawned
+// using the GameData passed. The client and server Conn returned are then ready to write and read packets.
+// Because the Dialer passed cannot be authenticated without a TokenSource, ListenConfig.AuthenticationDisabled
+// should generally be set to true.
+// Pipe is mainly useful for testing code that uses a Conn without needing a real network or client.
+func Pipe(cfg ListenConfig, d Dialer, data GameData) (client, server *Conn, err error) {
+	// The Listener requires the address dialed to be a valid UDP address, so a unique port on the loopback
+	// address is used. No UDP socket is ever opened.
+	address := fmt.Sprintf("127.0.0.1:%v", pipeCount.Add(1)%65535+1)
+	l, err := cfg.Listen("pipe", address)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Closing the Listener does not close connections already accepted.
+	defer l.Close()
+
+	started := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			started <- err
+			return
+		}
+		server = c.(*Conn)
+		if err := server.StartGame(data); err != nil {
+			_ = server.Close()
+			started <- err
+			return
+		}
+		started <- nil
+	}()
+	// fail closes both ends of the connection if setting it up failed. The Listener is closed first so that a
+	// pending Accept returns, after which the server side is waited for, so that it is never left open.
+	fail := func(err error) (*Conn, *Conn, error) {
+		_ = l.Close()
+		if client != nil {
+			_ = client.Close()
+		}
+		if <-started == nil {
+			_ = server.Close()
+		}
+		return nil, nil, err
+	}
+	if client, err = d.Dial("pipe", address); err != nil {
+		return fail(err)
+	}
+	if err := client.DoSpawn(); err != nil {
+		return fail(err)
+	}
+	if err := <-started; err != nil {
+		_ = client.Close()
+		return nil, nil, err
+	}
+	return client, server, nil
+}
+
+// pipeCount is used to generate a unique address for every call to Pipe.
+var pipeCount atomic.Uint64
+
+// PipeNetwork is an implementation of a Network that connects a Dialer to a Listener in the same process
+// using in-memory connections. Unlike those returned by net.Pipe, these connections preserve message
+// boundaries and writes to them never block, like with RakNet. Any address may be listened on, and dialing
+// an address connects to the Listener listening on it. PipeNetwork is registered under the "pipe" network.
+// Note that a Listener only accepts clients that dialed a valid UDP address, such as "127.0.0.1:19132", so
+// the addresses used should be of that form.
+type PipeNetwork struct{}
+
+// pipeListeners holds all pipeListeners currently listening, indexed by their address.
+var pipeListeners = struct {
+	sync.Mutex
+	m map[string]*pipeListener
+}{m: map[string]*pipeListener{}}
+
+// DialContext ...
+func (PipeNetwork) DialContext(ctx context.Context, address string) (net.Conn, error) {
+	pipeListeners.Lock()
+	l, ok := pipeListeners.m[address]
+	pipeListeners.Unlock()
+	if !ok {
+		return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(address), Err: errors.New("connection refused")}
+	}
+	c, s := newPipe(pipeAddr("client"), l.addr)
+	select {
+	case l.incoming <- s:
+		return c, nil
+	case <-l.closed:
+		return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(address), Err: errors.New("connection refused")}
+	case <-ctx.Done():
+		return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(address), Err: ctx.Err()}
+	}
+}
+
+// PingContext always returns an error: Pipe connections do not support pinging.
+func (PipeNetwork) PingContext(context.Context, string) ([]byte, error) {
+	return nil, errors.New("ping: not supported by pipe network")
+}
+
+// Listen ...
+func (PipeNetwork) Listen(address string) (NetworkListener, error) {
+	pipeListeners.Lock()
+	defer pipeListeners.Unlock()
+	if _, ok := pipeListeners.m[address]; ok {
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: pipeAddr(address), Err: errors.New("address already in use")}
+	}
+	l := &pipeListener{addr: pipeAddr(address), id: rand.Int64(), incoming: make(chan *pipeConn), closed: make(chan struct{})}
+	pipeListeners.m[address] = l
+	return l, nil
+}
+
+// pipeListener is a NetworkListener that accepts connections dialed using a PipeNetwork.
+type pipeListener struct {
+	addr     pipeAddr
+	id       int64
+	incoming chan *pipeConn
+	once     sync.Once
+	closed   chan struct{}
+}
+
+// Accept waits for a connection to be dialed to the address of the pipeListener and returns it.
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.addr, Err: net.ErrClosed}
+	}
+}
+
+// Close stops the pipeListener from accepting new connections and frees its address.
+func (l *pipeListener) Close() error {
+	l.once.Do(func() {
+		pipeListeners.Lock()
+		delete(pipeListeners.m, string(l.addr))
+		pipeListeners.Unlock()
+		close(l.closed)
+	})
+	return nil
+}
+
+// Addr ...
+func (l *pipeListener) Addr() net.Addr {
+	return l.addr
+}
+
+// ID ...
+func (l *pipeListener) ID() int64 {
+	return l.id
+}
+
+// PongData is a no-op: Pipe connections do not support pinging.
+func (l *pipeListener) PongData([]byte) {}
+
+// pipeAddr is the net.Addr of a pipeListener.
+type pipeAddr string
+
+// Network ...
+func (pipeAddr) Network() string {
+	return "pipe"
+}
+
+// String ...
+func (addr pipeAddr) String() string {
+	return string(addr)
+}
+
+// pipe holds the state shared by both ends of a connection created by newPipe.
+type pipe struct {
+	once   sync.Once
+	closed chan struct{}
+}
+
+// pipeConn is one end of an in-memory connection. Each call to Write on one end results in one call to Read
+// on the other end returning the same data.
+type pipeConn struct {
+	p             *pipe
+	local, remote pipeAddr
+	in, out       *pipeQueue
+}
+
+// pipeQueue is an unbounded queue of messages written to a pipeConn.
+type pipeQueue struct {
+	mu       sync.Mutex
+	messages [][]byte
+	notify   chan struct{}
+}
+
+// newPipe creates two pipeConns connected to each other.
+func newPipe(client, server pipeAddr) (*pipeConn, *pipeConn) {
+	p := &pipe{closed: make(chan struct{})}
+	a, b := &pipeQueue{notify: make(chan struct{}, 1)}, &pipeQueue{notify: make(chan struct{}, 1)}
+	return &pipeConn{p: p, local: client, remote: server, in: a, out: b},
+		&pipeConn{p: p, local: server, remote: client, in: b, out: a}
+}
+
+// Read reads the next message written to the other end of the pipeConn into b. If b is smaller than the
+// message, the rest of the message is discarded.
+func (c *pipeConn) Read(b []byte) (n int, err error) {
+	for {
+		c.in.mu.Lock()
+		if len(c.in.messages) > 0 {
+			n = copy(b, c.in.messages[0])
+			c.in.messages[0] = nil
+			c.in.messages = c.in.messages[1:]
+			c.in.mu.Unlock()
+			return n, nil
+		}
+		c.in.mu.Unlock()
+
+		select {
+		case <-c.in.notify:
+		case <-c.p.closed:
+			return 0, io.EOF
+		}
+	}
+}
+
+// Write writes a copy of b to the other end of the pipeConn. Write never blocks.
+func (c *pipeConn) Write(b []byte) (n int, err error) {
+	select {
+	case <-c.p.closed:
+		return 0, &net.OpError{Op: "write", Net: "pipe", Source: c.local, Addr: c.remote, Err: net.ErrClosed}
+	default:
+	}
+	c.out.mu.Lock()
+	c.out.messages = append(c.out.messages, slices.Clone(b))
+	c.out.mu.Unlock()
+
+	select {
+	case c.out.notify <- struct{}{}:
+	default:
+	}
+	return len(b), nil
+}
+
+// Close closes both ends of the pipeConn.
+func (c *pipeConn) Close() error {
+	c.p.once.Do(func() {
+		close(c.p.closed)
+	})
+	return nil
+}
+
+// LocalAddr ...
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr ...
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline is not supported by pipeConn and always returns an error.
+func (c *pipeConn) SetDeadline(time.Time) error {
+	return errPipeDeadline
+}
+
+// SetReadDeadline is not supported by pipeConn and always returns an error.
+func (c *pipeConn) SetReadDeadline(time.Time) error {
+	return errPipeDeadline
+}
+
+// SetWriteDeadline is not supported by pipeConn and always returns an error.
+func (c *pipeConn) SetWriteDeadline(time.Time) error {
+	return errPipeDeadline
+}
+
+// errPipeDeadline is returned when setting a deadline on a pipeConn.
+var errPipeDeadline = errors.New("deadlines not supported by pipe network")
+
+// init registers the pipe network.
+func init() {
+	RegisterNetwork("pipe", func(*slog.Logger) Network { return PipeNetwork{} })
+}
@@ -0,0 +1,523 @@
+package minecraft_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestPipeLogin checks that the identity of a client is passed to the server during the login sequence and
+// that packets may be written both ways once the client has spawned.
+func TestPipeLogin(t *testing.T) {
+	d := minecraft.Dialer{IdentityData: login.IdentityData{DisplayName: "Pipe", Identity: uuid.NewString()}}
+	client, server := pipe(t, minecraft.ListenConfig{AuthenticationDisabled: true}, d)
+
+	if name := server.IdentityData().DisplayName; name != "Pipe" {
+		t.Errorf("server identity: expected display name Pipe, got %v", name)
+	}
+	if id := server.IdentityData().Identity; id != d.IdentityData.Identity {
+		t.Errorf("server identity: expected identity %v, got %v", d.IdentityData.Identity, id)
+	}
+	exchangeText(t, client, server, "client to server")
+	exchangeText(t, server, client, "server to client")
+
+	if latency := client.Latency(); latency != 0 {
+		t.Errorf("client latency: expected 0 without round trip measurements, got %v", latency)
+	}
+}
+
+// TestPipeLoginAuthenticationRequired checks that a client that is not authenticated cannot log in to a
+// Listener that requires authentication.
+func TestPipeLoginAuthenticationRequired(t *testing.T) {
+	client, server, err := minecraft.Pipe(minecraft.ListenConfig{}, minecraft.Dialer{}, minecraft.GameData{})
+	if err == nil {
+		_ = client.Close()
+		_ = server.Close()
+		t.Fatalf("expected an error logging in without authentication")
+	}
+}
+
+// TestPipeCustomDisconnect checks that a Conn with a custom packet registered with the ID of the Disconnect
+// packet returns that packet from ReadPacket rather than closing the connection.
+func TestPipeCustomDisconnect(t *testing.T) {
+	d := minecraft.Dialer{CustomPackets: packet.Pool{
+		packet.IDDisconnect: func() packet.Packet { return &packet.Unknown{PacketID: packet.IDDisconnect} },
+	}}
+	client, server := pipe(t, minecraft.ListenConfig{AuthenticationDisabled: true}, d)
+
+	if err := server.WritePacket(&packet.Disconnect{Message: "custom"}); err != nil {
+		t.Fatalf("write packet: %v", err)
+	}
+	if err := server.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for {
+		pk, err := client.ReadPacket()
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		if unknown, ok := pk.(*packet.Unknown); ok && unknown.PacketID == packet.IDDisconnect {
+			break
+		}
+	}
+	exchangeText(t, server, client, "still connected")
+}
+
+// TestPipeEncryption checks that packets written after the login sequence are encrypted, unless encryption
+// was disabled in the ListenConfig. The packet written is small enough not to be compressed, so that its
+// content is only hidden by encryption.
+func TestPipeEncryption(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		t.Run(map[bool]string{false: "Enabled", true: "Disabled"}[disabled], func(t *testing.T) {
+			rec := &recordConn{}
+			cfg := minecraft.ListenConfig{
+				AuthenticationDisabled: true,
+				EncryptionDisabled:     disabled,
+				WrapConn: func(conn net.Conn) (net.Conn, error) {
+					rec.Conn = conn
+					return rec, nil
+				},
+			}
+			client, server := pipe(t, cfg, minecraft.Dialer{})
+
+			const message = "plain text that must not be readable when encrypted"
+			exchangeText(t, server, client, message)
+			if visible := rec.contains([]byte(message)); visible != disabled {
+				t.Errorf("message visible in data written: expected %v, got %v", disabled, visible)
+			}
+			exchangeText(t, client, server, message)
+		})
+	}
+}
+
+// TestPipeHandshakeTampered checks that a client refuses a ServerToClientHandshake of which the JWT was
+// changed after it was signed by the server, so that it fails to join and never enables encryption.
+func TestPipeHandshakeTampered(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	otherPub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	tests := map[string]func(t *testing.T, segments [][]byte){
+		"Payload": func(t *testing.T, segments [][]byte) {
+			// The salt is changed rather than any byte of the payload, so that the payload is still valid JSON
+			// and only the signature check can reject it.
+			var claims map[string]string
+			if err := json.Unmarshal(segments[1], &claims); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			salt := []byte(claims["salt"])
+			salt[0] = map[bool]byte{true: 'B', false: 'A'}[salt[0] == 'A']
+			claims["salt"] = string(salt)
+			segments[1], _ = json.Marshal(claims)
+		},
+		"Signature": func(t *testing.T, segments [][]byte) {
+			segments[2][0] ^= 1
+		},
+		"X5U": func(t *testing.T, segments [][]byte) {
+			var header map[string]any
+			if err := json.Unmarshal(segments[0], &header); err != nil {
+				t.Fatalf("decode header: %v", err)
+			}
+			header["x5u"] = base64.StdEncoding.EncodeToString(otherPub)
+			segments[0], _ = json.Marshal(header)
+		},
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			tampered := false
+			cfg := minecraft.ListenConfig{
+				AuthenticationDisabled: true,
+				WrapConn: func(conn net.Conn) (net.Conn, error) {
+					return &rewriteConn{
+						Conn: conn,
+						pk:   func() packet.Packet { return &packet.ServerToClientHandshake{} },
+						rewrite: func(pk packet.Packet) {
+							handshake := pk.(*packet.ServerToClientHandshake)
+							segments := bytes.Split(handshake.JWT, []byte("."))
+							for i, segment := range segments {
+								segments[i], _ = base64.RawURLEncoding.DecodeString(string(segment))
+							}
+							tamper(t, segments)
+							for i, segment := range segments {
+								segments[i] = []byte(base64.RawURLEncoding.EncodeToString(segment))
+							}
+							handshake.JWT, tampered = bytes.Join(segments, []byte(".")), true
+						},
+					}, nil
+				},
+			}
+			rec := &plainConn{}
+			d := minecraft.Dialer{WrapConn: func(conn net.Conn) (net.Conn, error) {
+				rec.Conn = conn
+				return rec, nil
+			}}
+			client, server, err := minecraft.Pipe(cfg, d, minecraft.GameData{})
+			if err == nil {
+				_ = client.Close()
+				_ = server.Close()
+				t.Fatalf("expected an error dialing with a tampered ServerToClientHandshake")
+			}
+			if !tampered {
+				t.Fatalf("ServerToClientHandshake was never written")
+			}
+			if !strings.Contains(err.Error(), "ServerToClientHandshake") {
+				t.Errorf("expected an error handling the ServerToClientHandshake, got %v", err)
+			}
+			if rec.encrypted() {
+				t.Errorf("client enabled encryption after a tampered ServerToClientHandshake")
+			}
+		})
+	}
+}
+
+// TestPipeResourcePacks checks that a client downloads the resource packs of a Listener in chunks and ends
+// up with the same packs.
+func TestPipeResourcePacks(t *testing.T) {
+	pack := testPack(t, 20000)
+	cfg := minecraft.ListenConfig{
+		AuthenticationDisabled: true,
+		ResourcePacks:          []*resource.Pack{pack},
+		ResourcePackChunkSize:  4096,
+	}
+	client, server := pipe(t, cfg, minecraft.Dialer{})
+
+	packs := client.ResourcePacks()
+	if len(packs) != 1 {
+		t.Fatalf("client resource packs: expected 1 pack, got %v", len(packs))
+	}
+	if packs[0].UUID() != pack.UUID() || packs[0].Checksum() != pack.Checksum() {
+		t.Errorf("client resource pack: expected %v with checksum %x, got %v with checksum %x", pack, pack.Checksum(), packs[0], packs[0].Checksum())
+	}
+	if result := server.ResourcePackResults()[pack.UUID().String()]; result != minecraft.ResourcePackDownloaded {
+		t.Errorf("server resource pack result: expected %v, got %v", minecraft.ResourcePackDownloaded, result)
+	}
+}
+
+// TestPipeResourcePacksDeclined checks that a client that declines a required pack using
+// Dialer.DownloadResourcePack skips it and still joins, while one declining it using
+// Dialer.ResourcePackPolicy refuses the packs and fails to join.
+func TestPipeResourcePacksDeclined(t *testing.T) {
+	cfg := minecraft.ListenConfig{
+		AuthenticationDisabled: true,
+		ResourcePacks:          []*resource.Pack{testPack(t, 100)},
+		TexturePacksRequired:   true,
+	}
+	t.Run("DownloadResourcePack", func(t *testing.T) {
+		client, server := pipe(t, cfg, minecraft.Dialer{
+			DownloadResourcePack: func(uuid.UUID, string, int, int) bool { return false },
+		})
+		if packs := client.ResourcePacks(); len(packs) != 0 {
+			t.Errorf("client resource packs: expected no packs, got %v", packs)
+		}
+		results := server.ResourcePackResults()
+		if len(results) != 1 {
+			t.Fatalf("server resource pack results: expected 1 result, got %v", len(results))
+		}
+		for id, result := range results {
+			if result != minecraft.ResourcePackNotRequested {
+				t.Errorf("server resource pack result of %v: expected %v, got %v", id, minecraft.ResourcePackNotRequested, result)
+			}
+		}
+	})
+	t.Run("ResourcePackPolicy", func(t *testing.T) {
+		client, server, err := minecraft.Pipe(cfg, minecraft.Dialer{
+			ResourcePackPolicy: func(protocol.TexturePackInfo, bool) bool { return false },
+		}, minecraft.GameData{})
+		if err == nil {
+			_ = client.Close()
+			_ = server.Close()
+			t.Fatalf("expected an error declining a required pack")
+		}
+	})
+}
+
+// TestPipeResourcePackChunkRequestInvalid checks that a client requesting chunks of a resource pack with an
+// empty UUID or the UUID of a pack that was not offered fails to join, rather than the request being
+// accepted.
+func TestPipeResourcePackChunkRequestInvalid(t *testing.T) {
+	cfg := minecraft.ListenConfig{
+		AuthenticationDisabled: true,
+		EncryptionDisabled:     true,
+		ResourcePacks:          []*resource.Pack{testPack(t, 100)},
+	}
+	for name, id := range map[string]string{"Empty": "", "Unknown": uuid.NewString()} {
+		t.Run(name, func(t *testing.T) {
+			d := minecraft.Dialer{WrapConn: func(conn net.Conn) (net.Conn, error) {
+				return chunkRequestConn(conn, id), nil
+			}}
+			client, server, err := minecraft.Pipe(cfg, d, minecraft.GameData{})
+			if err == nil {
+				_ = client.Close()
+				_ = server.Close()
+				t.Fatalf("expected an error requesting chunks of resource pack %q", id)
+			}
+		})
+	}
+}
+
+// TestPipeResourcePackDownloadURL checks that a resource pack with a download URL is never sent over the
+// connection: Clients skip the pack, requests for it are not queued and requests for its chunks are
+// rejected. Packs without a URL are still sent.
+func TestPipeResourcePackDownloadURL(t *testing.T) {
+	pack, urlPack := testPack(t, 100), testPack(t, 100).WithDownloadURL("https://example.com/pack.zip")
+	cfg := minecraft.ListenConfig{
+		AuthenticationDisabled: true,
+		EncryptionDisabled:     true,
+		ResourcePacks:          []*resource.Pack{pack, urlPack},
+	}
+	checkResults := func(t *testing.T, client, server *minecraft.Conn) {
+		t.Helper()
+		packs := client.ResourcePacks()
+		if len(packs) != 1 || packs[0].UUID() != pack.UUID() {
+			t.Errorf("client resource packs: expected only %v, got %v", pack, packs)
+		}
+		results := server.ResourcePackResults()
+		if result := results[pack.UUID().String()]; result != minecraft.ResourcePackDownloaded {
+			t.Errorf("server resource pack result: expected %v, got %v", minecraft.ResourcePackDownloaded, result)
+		}
+		if result := results[urlPack.UUID().String()]; result != minecraft.ResourcePackDownloadURL {
+			t.Errorf("server resource pack result with URL: expected %v, got %v", minecraft.ResourcePackDownloadURL, result)
+		}
+	}
+	t.Run("Skipped", func(t *testing.T) {
+		client, server := pipe(t, cfg, minecraft.Dialer{})
+		checkResults(t, client, server)
+	})
+	t.Run("Requested", func(t *testing.T) {
+		d := minecraft.Dialer{WrapConn: func(conn net.Conn) (net.Conn, error) {
+			return &rewriteConn{
+				Conn: conn,
+				pk:   func() packet.Packet { return &packet.ResourcePackClientResponse{} },
+				rewrite: func(pk packet.Packet) {
+					if resp := pk.(*packet.ResourcePackClientResponse); resp.Response == packet.PackResponseSendPacks {
+						resp.PacksToDownload = append(resp.PacksToDownload, urlPack.UUID().String()+"_"+urlPack.Version())
+					}
+				},
+			}, nil
+		}}
+		client, server := pipe(t, cfg, d)
+		checkResults(t, client, server)
+	})
+	t.Run("ChunkRequest", func(t *testing.T) {
+		d := minecraft.Dialer{WrapConn: func(conn net.Conn) (net.Conn, error) {
+			return chunkRequestConn(conn, urlPack.UUID().String()), nil
+		}}
+		client, server, err := minecraft.Pipe(cfg, d, minecraft.GameData{})
+		if err == nil {
+			_ = client.Close()
+			_ = server.Close()
+			t.Fatalf("expected an error requesting chunks of a resource pack with a download URL")
+		}
+	})
+}
+
+// pipe calls minecraft.Pipe with the ListenConfig and Dialer passed and closes both connections returned
+// once the test finishes.
+func pipe(t *testing.T, cfg minecraft.ListenConfig, d minecraft.Dialer) (client, server *minecraft.Conn) {
+	t.Helper()
+	client, server, err := minecraft.Pipe(cfg, d, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client, server
+}
+
+// exchangeText writes a Text packet with the message passed to one connection and reads packets from the
+// other until it is received.
+func exchangeText(t *testing.T, from, to *minecraft.Conn, message string) {
+	t.Helper()
+	if err := from.WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: message}); err != nil {
+		t.Fatalf("write packet: %v", err)
+	}
+	if err := from.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for {
+		pk, err := to.ReadPacket()
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		if text, ok := pk.(*packet.Text); ok {
+			if text.Message != message {
+				t.Fatalf("read text: expected %q, got %q", message, text.Message)
+			}
+			return
+		}
+	}
+}
+
+// testPack creates a resource pack with a texture module and a file of n random bytes.
+func testPack(t *testing.T, n int) *resource.Pack {
+	t.Helper()
+	return testPackWithDependencies(t, uuid.New(), n)
+}
+
+// testPackWithDependencies creates a resource pack like testPack, with the UUID passed and a dependency on
+// each of the packs with the UUIDs passed.
+func testPackWithDependencies(t *testing.T, id uuid.UUID, n int, deps ...uuid.UUID) *resource.Pack {
+	t.Helper()
+	m := resource.Manifest{
+		FormatVersion: 2,
+		Header:        resource.Header{Name: "Test", UUID: id, Version: [3]int{1, 0, 0}},
+		Modules:       []resource.Module{{UUID: uuid.NewString(), Type: resource.ModuleTypeResources, Version: [3]int{1, 0, 0}}},
+	}
+	for _, dep := range deps {
+		m.Dependencies = append(m.Dependencies, resource.Dependency{UUID: dep.String(), Version: [3]int{1, 0, 0}})
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("encode manifest: %v", err)
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(rand.N(256))
+	}
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range map[string][]byte{"manifest.json": manifest, "textures/data.bin": data} {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatalf("create %v: %v", name, err)
+		}
+		_, _ = f.Write(content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	pack, err := resource.Read(buf)
+	if err != nil {
+		t.Fatalf("read pack: %v", err)
+	}
+	return pack
+}
+
+// recordConn is a net.Conn that records all data written to it.
+type recordConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written []byte
+}
+
+// Write ...
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.written = append(c.written, b...)
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+// contains checks if the data passed was written to the recordConn.
+func (c *recordConn) contains(b []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Contains(c.written, b)
+}
+
+// plainConn is a net.Conn that checks if every batch written to it may be decoded without encryption.
+type plainConn struct {
+	net.Conn
+	mu  sync.Mutex
+	enc bool
+}
+
+// Write ...
+func (c *plainConn) Write(b []byte) (int, error) {
+	dec := packet.NewDecoder(bytes.NewReader(b))
+	dec.EnableCompression()
+	if _, err := dec.Decode(); err != nil {
+		// Batches written before compression is enabled hold a network settings request, which may always be
+		// decoded without compression.
+		if _, err := packet.NewDecoder(bytes.NewReader(b)).Decode(); err != nil {
+			c.mu.Lock()
+			c.enc = true
+			c.mu.Unlock()
+		}
+	}
+	return c.Conn.Write(b)
+}
+
+// encrypted checks if any batch written to the plainConn could not be decoded without encryption.
+func (c *plainConn) encrypted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc
+}
+
+// rewriteConn is a net.Conn that calls rewrite for every packet of the type returned by pk written to it,
+// after which the packet is written with the changes made. It only works for connections that are not
+// encrypted.
+type rewriteConn struct {
+	net.Conn
+	pk      func() packet.Packet
+	rewrite func(pk packet.Packet)
+}
+
+// Write ...
+func (c *rewriteConn) Write(b []byte) (int, error) {
+	dec := packet.NewDecoder(bytes.NewReader(b))
+	dec.EnableCompression()
+	pks, err := dec.Decode()
+	if err != nil {
+		// Batches written before compression is enabled are passed on as is.
+		return c.Conn.Write(b)
+	}
+	replaced := false
+	for i, data := range pks {
+		r := bytes.NewBuffer(data)
+		var h packet.Header
+		pk := c.pk()
+		if err := h.Read(r); err != nil || h.PacketID != pk.ID() {
+			continue
+		}
+		pk.Marshal(protocol.NewReader(r, 0, false))
+		c.rewrite(pk)
+
+		buf := new(bytes.Buffer)
+		_ = h.Write(buf)
+		pk.Marshal(protocol.NewWriter(buf, 0))
+		pks[i], replaced = buf.Bytes(), true
+	}
+	if !replaced {
+		return c.Conn.Write(b)
+	}
+	enc := packet.NewEncoder(c.Conn)
+	enc.EnableCompression(packet.FlateCompression)
+	if err := enc.Encode(pks); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// chunkRequestConn returns a rewriteConn that replaces the UUID of every ResourcePackChunkRequest written to
+// conn with the UUID passed.
+func chunkRequestConn(conn net.Conn, id string) *rewriteConn {
+	return &rewriteConn{
+		Conn:    conn,
+		pk:      func() packet.Packet { return &packet.ResourcePackChunkRequest{} },
+		rewrite: func(pk packet.Packet) { pk.(*packet.ResourcePackChunkRequest).UUID = id },
+	}
+}
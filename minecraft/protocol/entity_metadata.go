@@ -307,3 +307,55 @@ func (m EntityMetadata) Flag(key uint32, index uint8) bool {
 		return v.(int64)&(1<<int64(index)) != 0
 	}
 }
+
+// EntityFlag checks if the entity flag with the index passed, which is one of the EntityDataFlag constants, is
+// set. Unlike Flag, EntityFlag looks up flags with an index of 64 or higher in EntityDataKeyFlagsTwo, and it
+// returns false rather than panicking if the metadata does not hold the flags, as may be the case for metadata
+// received in a packet such as SetActorData.
+func (m EntityMetadata) EntityFlag(index int) bool {
+	key := uint32(EntityDataKeyFlags)
+	if index >= 64 {
+		key, index = EntityDataKeyFlagsTwo, index-64
+	}
+	v, ok := m[key].(int64)
+	return ok && index >= 0 && v&(1<<index) != 0
+}
+
+// OnFire checks if the EntityDataFlagOnFire flag is set.
+func (m EntityMetadata) OnFire() bool {
+	return m.EntityFlag(EntityDataFlagOnFire)
+}
+
+// Sneaking checks if the EntityDataFlagSneaking flag is set.
+func (m EntityMetadata) Sneaking() bool {
+	return m.EntityFlag(EntityDataFlagSneaking)
+}
+
+// Sprinting checks if the EntityDataFlagSprinting flag is set.
+func (m EntityMetadata) Sprinting() bool {
+	return m.EntityFlag(EntityDataFlagSprinting)
+}
+
+// Invisible checks if the EntityDataFlagInvisible flag is set.
+func (m EntityMetadata) Invisible() bool {
+	return m.EntityFlag(EntityDataFlagInvisible)
+}
+
+// Baby checks if the EntityDataFlagBaby flag is set.
+func (m EntityMetadata) Baby() bool {
+	return m.EntityFlag(EntityDataFlagBaby)
+}
+
+// Name returns the name tag held in the metadata under EntityDataKeyName. False is returned if the metadata
+// holds no name tag.
+func (m EntityMetadata) Name() (string, bool) {
+	name, ok := m[EntityDataKeyName].(string)
+	return name, ok
+}
+
+// Scale returns the scale held in the metadata under EntityDataKeyScale. False is returned if the metadata
+// holds no scale, in which case the entity has the default scale of 1.
+func (m EntityMetadata) Scale() (float32, bool) {
+	scale, ok := m[EntityDataKeyScale].(float32)
+	return scale, ok
+}
@@ -0,0 +1,78 @@
+package protocol_test
+
+import (
+	"bytes"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"reflect"
+	"testing"
+)
+
+// TestEntityMetadataRoundTrip checks that EntityMetadata holding values of every type is read back unchanged
+// after writing it, and that the typed accessors return the values written.
+func TestEntityMetadataRoundTrip(t *testing.T) {
+	m := protocol.NewEntityMetadata()
+	m.SetFlag(protocol.EntityDataKeyFlags, protocol.EntityDataFlagOnFire)
+	m.SetFlag(protocol.EntityDataKeyFlags, protocol.EntityDataFlagInvisible)
+	m.SetFlag(protocol.EntityDataKeyFlags, protocol.EntityDataFlagBaby)
+	m.SetFlag(protocol.EntityDataKeyFlagsTwo, 1)
+	m.SetFlag(protocol.EntityDataKeyPlayerFlags, 1)
+	m[protocol.EntityDataKeyName] = "Steve"
+	m[protocol.EntityDataKeyScale] = float32(0.5)
+	m[protocol.EntityDataKeyColorIndex] = byte(3)
+	m[protocol.EntityDataKeyHurt] = int32(-7)
+	m[protocol.EntityDataKeyAirSupply] = int16(300)
+	m[protocol.EntityDataKeyBedPosition] = protocol.BlockPos{1, -64, 3}
+	m[protocol.EntityDataKeyOwner] = int64(-1)
+	m[protocol.EntityDataKeySeatOffset] = mgl32.Vec3{0, 1.5, -0.25}
+	m[protocol.EntityDataKeyNPCData] = map[string]any{"name": "npc", "count": int32(2)}
+
+	buf := new(bytes.Buffer)
+	written := map[uint32]any(m)
+	protocol.NewWriter(buf, 0).EntityMetadata(&written)
+	var read map[uint32]any
+	protocol.NewReader(buf, 0, true).EntityMetadata(&read)
+	if buf.Len() != 0 {
+		t.Errorf("%v bytes left after reading entity metadata", buf.Len())
+	}
+	if !reflect.DeepEqual(written, read) {
+		t.Fatalf("entity metadata changed after round trip:\nwritten %#v\nread    %#v", written, read)
+	}
+
+	out := protocol.EntityMetadata(read)
+	flags := map[string]struct{ got, expected bool }{
+		"OnFire":    {out.OnFire(), true},
+		"Invisible": {out.Invisible(), true},
+		"Baby":      {out.Baby(), true},
+		"Sneaking":  {out.Sneaking(), false},
+		"Sprinting": {out.Sprinting(), false},
+		"FlagsTwo":  {out.EntityFlag(65), true},
+		"Player":    {out.Flag(protocol.EntityDataKeyPlayerFlags, 1), true},
+	}
+	for name, flag := range flags {
+		if flag.got != flag.expected {
+			t.Errorf("flag %v: expected %v, got %v", name, flag.expected, flag.got)
+		}
+	}
+	if name, ok := out.Name(); !ok || name != "Steve" {
+		t.Errorf("name: expected Steve, got %q (present: %v)", name, ok)
+	}
+	if scale, ok := out.Scale(); !ok || scale != 0.5 {
+		t.Errorf("scale: expected 0.5, got %v (present: %v)", scale, ok)
+	}
+}
+
+// TestEntityMetadataAccessorsMissing checks that the typed accessors of EntityMetadata report values that are
+// not present rather than panicking, as may happen for metadata received in a SetActorData packet.
+func TestEntityMetadataAccessorsMissing(t *testing.T) {
+	m := protocol.EntityMetadata{}
+	if m.OnFire() || m.Invisible() || m.Baby() || m.EntityFlag(70) || m.EntityFlag(-1) {
+		t.Errorf("expected no flags to be set in empty metadata")
+	}
+	if _, ok := m.Name(); ok {
+		t.Errorf("expected no name in empty metadata")
+	}
+	if _, ok := m.Scale(); ok {
+		t.Errorf("expected no scale in empty metadata")
+	}
+}
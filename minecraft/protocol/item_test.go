@@ -0,0 +1,88 @@
+package protocol_test
+
+import (
+	"bytes"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"reflect"
+	"testing"
+)
+
+// shieldID is the network ID of the shield used in the tests. Shields have an extra field in their data.
+const shieldID = 355
+
+// itemStacks returns the item stacks used in the item round trip tests. Stacks are returned the way Reader
+// produces them, so that they may be compared with the stacks read.
+func itemStacks() map[string]protocol.ItemStack {
+	return map[string]protocol.ItemStack{
+		"Air": {NBTData: map[string]any{}},
+		"Simple": {
+			ItemType:      protocol.ItemType{NetworkID: 5, MetadataValue: 2},
+			Count:         64,
+			NBTData:       map[string]any{},
+			CanBePlacedOn: []string{},
+			CanBreak:      []string{},
+		},
+		"Full": {
+			ItemType:       protocol.ItemType{NetworkID: 312, MetadataValue: 0},
+			BlockRuntimeID: 1234,
+			Count:          1,
+			NBTData: map[string]any{
+				"Damage":  int32(5),
+				"display": map[string]any{"Name": "Sword", "Lore": []any{"Sharp"}},
+			},
+			CanBePlacedOn: []string{"minecraft:stone", "minecraft:dirt"},
+			CanBreak:      []string{"minecraft:glass"},
+		},
+		"Shield": {
+			ItemType:      protocol.ItemType{NetworkID: shieldID},
+			Count:         1,
+			NBTData:       map[string]any{},
+			CanBePlacedOn: []string{},
+			CanBreak:      []string{},
+		},
+	}
+}
+
+// TestItemInstanceRoundTrip checks that an ItemInstance written using Writer.ItemInstance is read back
+// unchanged by Reader.ItemInstance.
+func TestItemInstanceRoundTrip(t *testing.T) {
+	for name, stack := range itemStacks() {
+		t.Run(name, func(t *testing.T) {
+			in := protocol.ItemInstance{Stack: stack}
+			if stack.NetworkID != 0 {
+				in.StackNetworkID = 17
+			}
+			buf := new(bytes.Buffer)
+			protocol.NewWriter(buf, shieldID).ItemInstance(&in)
+
+			var out protocol.ItemInstance
+			protocol.NewReader(buf, shieldID, true).ItemInstance(&out)
+			if buf.Len() != 0 {
+				t.Errorf("%v bytes left after reading item instance", buf.Len())
+			}
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("item instance changed after round trip:\nwritten %#v\nread    %#v", in, out)
+			}
+		})
+	}
+}
+
+// TestItemRoundTrip checks that an ItemStack written using Writer.Item is read back unchanged by
+// Reader.Item.
+func TestItemRoundTrip(t *testing.T) {
+	for name, stack := range itemStacks() {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			protocol.NewWriter(buf, shieldID).Item(&stack)
+
+			var out protocol.ItemStack
+			protocol.NewReader(buf, shieldID, true).Item(&out)
+			if buf.Len() != 0 {
+				t.Errorf("%v bytes left after reading item stack", buf.Len())
+			}
+			if !reflect.DeepEqual(stack, out) {
+				t.Errorf("item stack changed after round trip:\nwritten %#v\nread    %#v", stack, out)
+			}
+		})
+	}
+}
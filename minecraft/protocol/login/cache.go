@@ -0,0 +1,90 @@
+package login
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// VerificationCache caches the tokens in login chains that were verified successfully. The tokens signed by
+// Mojang are reused by a client for many logins, so caching them saves the ECDSA signature verification of
+// these tokens for repeated logins of the same account. Tokens are still checked for expiry on every call to
+// Parse.
+// A VerificationCache is safe for use by multiple goroutines simultaneously. A nil *VerificationCache caches
+// nothing.
+type VerificationCache struct {
+	mu     sync.Mutex
+	size   int
+	ttl    time.Duration
+	tokens map[[32]byte]time.Time
+}
+
+// NewVerificationCache returns a VerificationCache that caches at most size tokens, each for the duration
+// ttl. Passing a size or ttl of 0 returns a cache that caches nothing.
+func NewVerificationCache(size int, ttl time.Duration) *VerificationCache {
+	return &VerificationCache{size: size, ttl: ttl, tokens: make(map[[32]byte]time.Time, max(size, 0))}
+}
+
+// Parse parses the login request passed like the Parse function of the package, but skips verifying the
+// signature of tokens that were verified before using the cache.
+func (c *VerificationCache) Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
+	return parse(request, c)
+}
+
+// key returns the key in the cache for the token and ecdsa.PublicKey passed.
+func (c *VerificationCache) key(token string, pub *ecdsa.PublicKey) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(token))
+	h.Write(pub.X.Bytes())
+	h.Write(pub.Y.Bytes())
+	return [32]byte(h.Sum(nil))
+}
+
+// verified checks if the token passed was verified using the ecdsa.PublicKey passed before, and if the
+// cached result has not yet expired.
+func (c *VerificationCache) verified(token string, pub *ecdsa.PublicKey) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 || c.ttl <= 0 {
+		return false
+	}
+	k := c.key(token, pub)
+	exp, ok := c.tokens[k]
+	if ok && time.Now().After(exp) {
+		delete(c.tokens, k)
+		return false
+	}
+	return ok
+}
+
+// store stores the token passed as verified using the ecdsa.PublicKey passed. If the cache is full, expired
+// tokens are removed first. If no tokens expired, an arbitrary token is removed.
+func (c *VerificationCache) store(token string, pub *ecdsa.PublicKey) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	if len(c.tokens) >= c.size {
+		for k, exp := range c.tokens {
+			if now.After(exp) {
+				delete(c.tokens, k)
+			}
+		}
+		for k := range c.tokens {
+			if len(c.tokens) < c.size {
+				break
+			}
+			delete(c.tokens, k)
+		}
+	}
+	c.tokens[c.key(token, pub)] = now.Add(c.ttl)
+}
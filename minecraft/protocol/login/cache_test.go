@@ -0,0 +1,78 @@
+package login
+
+import (
+	"github.com/go-jose/go-jose/v4/jwt"
+	"testing"
+	"time"
+)
+
+// TestVerificationCache checks that a VerificationCache stores the tokens it verified, and that these tokens
+// are not shared with other caches or with Parse.
+func TestVerificationCache(t *testing.T) {
+	request := loginStorm(t, 1)[0]
+	cache, other := NewVerificationCache(16, time.Minute), NewVerificationCache(16, time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := cache.Parse(request); err != nil {
+			t.Fatalf("parse request with cache: %v", err)
+		}
+	}
+	if _, _, _, err := Parse(request); err != nil {
+		t.Fatalf("parse request: %v", err)
+	}
+	// Three tokens in the chain and the client data token were verified.
+	if len(cache.tokens) != 4 {
+		t.Errorf("cache: expected 4 tokens, got %v", len(cache.tokens))
+	}
+	if len(other.tokens) != 0 {
+		t.Errorf("other cache: expected no tokens, got %v", len(other.tokens))
+	}
+}
+
+// BenchmarkParse measures parsing the login requests of an account that logs in repeatedly, as during a
+// login storm. Like a real client, the account signs a new first token and client data for every login,
+// while the tokens signed by the authentication service stay the same. With the verification cache enabled,
+// only the tokens that changed are verified.
+func BenchmarkParse(b *testing.B) {
+	requests := loginStorm(b, 64)
+	b.Run("Uncached", func(b *testing.B) {
+		benchmarkParse(b, requests, Parse)
+	})
+	b.Run("Cached", func(b *testing.B) {
+		benchmarkParse(b, requests, NewVerificationCache(1024, time.Minute).Parse)
+	})
+}
+
+// benchmarkParse parses the requests passed in turn using the parse function passed and reports the amount
+// of logins parsed per second.
+func benchmarkParse(b *testing.B, requests [][]byte, parse func([]byte) (IdentityData, ClientData, AuthResult, error)) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := parse(requests[i%len(requests)]); err != nil {
+			b.Fatalf("parse request: %v", err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "logins/s")
+}
+
+// loginStorm creates n login requests of a single account. Each request has a chain of three tokens, of
+// which only the first is different for every request, and its own client data token.
+func loginStorm(b testing.TB, n int) [][]byte {
+	client, intermediate, identity := testKey(b), testKey(b), testKey(b)
+	now := time.Now()
+	claims := jwt.Claims{Issuer: "Mojang", Expiry: jwt.NewNumericDate(now.Add(time.Hour)), NotBefore: jwt.NewNumericDate(now.Add(-time.Hour))}
+	shared := chain{
+		signToken(b, intermediate, identityPublicKeyClaims{Claims: claims, IdentityPublicKey: MarshalPublicKey(&identity.PublicKey)}),
+		signToken(b, identity, identityClaims{Claims: claims, ExtraData: testIdentityData(), IdentityPublicKey: MarshalPublicKey(&client.PublicKey)}),
+	}
+	requests := make([][]byte, n)
+	for i := range requests {
+		first := jwt.Claims{Expiry: claims.Expiry, NotBefore: jwt.NewNumericDate(now.Add(-time.Hour - time.Duration(i)*time.Second))}
+		requests[i] = encodeRequest(&request{
+			Chain:    append(chain{signToken(b, client, identityPublicKeyClaims{Claims: first, IdentityPublicKey: MarshalPublicKey(&intermediate.PublicKey)})}, shared...),
+			RawToken: signToken(b, client, testClientData()),
+		})
+	}
+	return requests
+}
@@ -83,6 +83,29 @@ func (data IdentityData) Validate() error {
 	return nil
 }
 
+// XUIDValue parses the XUID of the IdentityData as an int64 and returns it. An error is returned if the XUID
+// is empty, which is the case if the player is not logged into its XBOX Live account, or if it is malformed.
+func (data IdentityData) XUIDValue() (int64, error) {
+	if data.XUID == "" {
+		return 0, fmt.Errorf("XUID is empty: player is not logged into XBOX Live")
+	}
+	xuid, err := strconv.ParseInt(data.XUID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("XUID must be parseable as an int64, but got %v", data.XUID)
+	}
+	return xuid, nil
+}
+
+// UUID parses the Identity of the IdentityData as a uuid.UUID and returns it. An error is returned if the
+// Identity is not a valid UUID or if it is the nil UUID.
+func (data IdentityData) UUID() (uuid.UUID, error) {
+	id, err := uuid.Parse(data.Identity)
+	if err != nil || id == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("UUID must be parseable as a valid UUID, but got %v", data.Identity)
+	}
+	return id, nil
+}
+
 // ClientData is a container of client specific data of a Login packet. It holds data such as the skin of a
 // player, but also its language code and device information.
 type ClientData struct {
@@ -199,6 +222,10 @@ type ClientData struct {
 	// Although this field is obviously here for a reason, allowing this is too dangerous and should never be
 	// done.
 	ThirdPartyNameOnly bool
+	// TenantID is the ID of the Microsoft 365 tenant that the player is signed in to. It is only set by clients
+	// of Minecraft: Education Edition and is empty for vanilla clients. A Dialer may set it to log in as an
+	// education edition client.
+	TenantID string `json:"TenantId,omitempty"`
 	// UIProfile is the UI profile used. For the 'Pocket' UI, this is 1. For the 'Classic' UI, this is 0.
 	UIProfile int
 	// TrustedSkin is a boolean indicating if the skin the client is using is trusted.
@@ -299,6 +326,12 @@ type SkinAnimation struct {
 	AnimationExpression int
 }
 
+// EducationEdition checks if the client data was sent by a client of Minecraft: Education Edition, which is
+// the case if its TenantID is set.
+func (data ClientData) EducationEdition() bool {
+	return data.TenantID != ""
+}
+
 // checkVersion is used to check if a version is an actual valid version. It must only contain numbers and
 // dots.
 var checkVersion = regexp.MustCompile("[0-9.]").MatchString
@@ -327,9 +360,15 @@ func (data ClientData) Validate() error {
 	if _, err := net.ResolveUDPAddr("udp", data.ServerAddress); err != nil {
 		return fmt.Errorf("ServerAddress must be resolveable as a UDP address, but got %v", data.ServerAddress)
 	}
+	if err := validateDimensions(data.SkinImageWidth, data.SkinImageHeight); err != nil {
+		return fmt.Errorf("SkinData is invalid: %w", err)
+	}
 	if err := base64DecLength(data.SkinData, data.SkinImageHeight*data.SkinImageWidth*4); err != nil {
 		return fmt.Errorf("SkinData is invalid: %w", err)
 	}
+	if err := validateDimensions(data.CapeImageWidth, data.CapeImageHeight); err != nil {
+		return fmt.Errorf("CapeData is invalid: %w", err)
+	}
 	if err := base64DecLength(data.CapeData, data.CapeImageHeight*data.CapeImageWidth*4); err != nil {
 		return fmt.Errorf("CapeData is invalid: %w", err)
 	}
@@ -339,6 +378,9 @@ func (data ClientData) Validate() error {
 		}
 	}
 	for _, anim := range data.AnimatedImageData {
+		if err := validateDimensions(anim.ImageWidth, anim.ImageHeight); err != nil {
+			return fmt.Errorf("invalid animated image data: %w", err)
+		}
 		if err := base64DecLength(anim.Image, anim.ImageHeight*anim.ImageWidth*4); err != nil {
 			return fmt.Errorf("invalid animated image data: %w", err)
 		}
@@ -371,6 +413,15 @@ func (data ClientData) Validate() error {
 	return nil
 }
 
+// validateDimensions checks if the width and height of an image passed are within bounds, so that the size
+// of the image computed from them does not overflow.
+func validateDimensions(width, height int) error {
+	if width < 0 || height < 0 || width > maxSkinDimension || height > maxSkinDimension {
+		return fmt.Errorf("invalid dimensions %vx%v: width and height must be between 0 and %v", width, height, maxSkinDimension)
+	}
+	return nil
+}
+
 // base64DecLength decodes the base64 data passed and checks if its length is one of the valid lengths
 // passed. If either of these checks fails, an error is returned.
 func base64DecLength(base64Data string, validLengths ...int) error {
@@ -39,6 +39,11 @@ func init() {
 // mojangKey holds the parsed Mojang ecdsa.PublicKey.
 var mojangKey = new(ecdsa.PublicKey)
 
+// ClockSkew is the maximum difference in time tolerated between the clock of the system and the clock used
+// to issue the tokens in a login chain. Parse rejects a login request if any of the tokens in its chain
+// expired (exp) or is not yet valid (nbf), taking ClockSkew into account.
+var ClockSkew = jwt.DefaultLeeway
+
 // AuthResult is returned by a call to Parse. It holds the ecdsa.PublicKey of the client and a bool that
 // indicates if the player was logged in with XBOX Live.
 type AuthResult struct {
@@ -52,7 +57,15 @@ type AuthResult struct {
 // Parse returns IdentityData and ClientData, of which IdentityData cannot under any circumstance be edited by
 // the client. Rather, it is obtained from an authentication endpoint. The ClientData can, however, be edited
 // freely by the client.
+// If any token in the chain expired or is not yet valid, Parse returns an error wrapping jwt.ErrExpired or
+// jwt.ErrNotValidYet respectively.
 func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
+	return parse(request, nil)
+}
+
+// parse parses the login request passed, skipping the verification of tokens that were verified before
+// according to the VerificationCache passed, which may be nil.
+func parse(request []byte, cache *VerificationCache) (IdentityData, ClientData, AuthResult, error) {
 	var (
 		iData IdentityData
 		cData ClientData
@@ -82,34 +95,36 @@ func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
 	switch len(req.Chain) {
 	case 1:
 		// Player was not authenticated with XBOX Live, meaning the one token in here is self-signed.
-		if err := parseFullClaim(req.Chain[0], key, &identityClaims); err != nil {
+		if err := parseFullClaim(req.Chain[0], key, cache, &identityClaims); err != nil {
 			return iData, cData, res, err
 		}
-		if err := identityClaims.Validate(jwt.Expected{Time: t}); err != nil {
+		if err := identityClaims.ValidateWithLeeway(jwt.Expected{Time: t}, ClockSkew); err != nil {
 			return iData, cData, res, fmt.Errorf("validate token 0: %w", err)
 		}
 	case 3:
 		// Player was (or should be) authenticated with XBOX Live, meaning the chain is exactly 3 tokens
 		// long.
 		var c jwt.Claims
-		if err := parseFullClaim(req.Chain[0], key, &c); err != nil {
+		if err := parseFullClaim(req.Chain[0], key, cache, &c); err != nil {
 			return iData, cData, res, fmt.Errorf("parse token 0: %w", err)
 		}
-		if err := c.Validate(jwt.Expected{Time: t}); err != nil {
+		if err := c.ValidateWithLeeway(jwt.Expected{Time: t}, ClockSkew); err != nil {
 			return iData, cData, res, fmt.Errorf("validate token 0: %w", err)
 		}
 		authenticated = bytes.Equal(key.X.Bytes(), mojangKey.X.Bytes()) && bytes.Equal(key.Y.Bytes(), mojangKey.Y.Bytes())
 
-		if err := parseFullClaim(req.Chain[1], key, &c); err != nil {
+		// Reset the claims so that no claims of token 0, such as its expiry, carry over to token 1.
+		c = jwt.Claims{}
+		if err := parseFullClaim(req.Chain[1], key, cache, &c); err != nil {
 			return iData, cData, res, fmt.Errorf("parse token 1: %w", err)
 		}
-		if err := c.Validate(jwt.Expected{Time: t, Issuer: iss}); err != nil {
+		if err := c.ValidateWithLeeway(jwt.Expected{Time: t, Issuer: iss}, ClockSkew); err != nil {
 			return iData, cData, res, fmt.Errorf("validate token 1: %w", err)
 		}
-		if err := parseFullClaim(req.Chain[2], key, &identityClaims); err != nil {
+		if err := parseFullClaim(req.Chain[2], key, cache, &identityClaims); err != nil {
 			return iData, cData, res, fmt.Errorf("parse token 2: %w", err)
 		}
-		if err := identityClaims.Validate(jwt.Expected{Time: t, Issuer: iss}); err != nil {
+		if err := identityClaims.ValidateWithLeeway(jwt.Expected{Time: t, Issuer: iss}, ClockSkew); err != nil {
 			return iData, cData, res, fmt.Errorf("validate token 2: %w", err)
 		}
 		if authenticated != (identityClaims.ExtraData.XUID != "") {
@@ -121,7 +136,7 @@ func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
 	default:
 		return iData, cData, res, fmt.Errorf("unexpected login chain length %v", len(req.Chain))
 	}
-	if err := parseFullClaim(req.RawToken, key, &cData); err != nil {
+	if err := parseFullClaim(req.RawToken, key, cache, &cData); err != nil {
 		return iData, cData, res, fmt.Errorf("parse client data: %w", err)
 	}
 	if strings.Count(cData.ServerAddress, ":") > 1 && cData.ServerAddress[0] != '[' {
@@ -155,16 +170,25 @@ func parseLoginRequest(requestData []byte) (*request, error) {
 }
 
 // parseFullClaim parses and verifies a full claim using the ecdsa.PublicKey passed. The key passed is updated
-// if the claim holds an identityPublicKey field.
+// if the claim holds an identityPublicKey field. The signature is not verified again if the VerificationCache
+// passed holds the claim.
 // The value v passed is decoded into when reading the claims.
-func parseFullClaim(claim string, key *ecdsa.PublicKey, v any) error {
+func parseFullClaim(claim string, key *ecdsa.PublicKey, cache *VerificationCache, v any) error {
 	tok, err := jwt.ParseSigned(claim, []jose.SignatureAlgorithm{jose.ES384})
 	if err != nil {
 		return fmt.Errorf("error parsing signed token: %w", err)
 	}
 	var m map[string]any
-	if err := tok.Claims(key, v, &m); err != nil {
-		return fmt.Errorf("error verifying claims of token: %w", err)
+	if cache.verified(claim, key) {
+		// The signature of this exact token was verified with this key before, so we only decode the claims.
+		if err := tok.UnsafeClaimsWithoutVerification(v, &m); err != nil {
+			return fmt.Errorf("error decoding claims of token: %w", err)
+		}
+	} else {
+		if err := tok.Claims(key, v, &m); err != nil {
+			return fmt.Errorf("error verifying claims of token: %w", err)
+		}
+		cache.store(claim, key)
 	}
 	newKey, present := m["identityPublicKey"]
 	if present {
@@ -304,6 +328,15 @@ func (c identityClaims) Validate(e jwt.Expected) error {
 	return c.ExtraData.Validate()
 }
 
+// ValidateWithLeeway validates the identity claims held by the struct like Validate, but tolerates the
+// leeway passed when checking the expiry and not before claims.
+func (c identityClaims) ValidateWithLeeway(e jwt.Expected, leeway time.Duration) error {
+	if err := c.Claims.ValidateWithLeeway(e, leeway); err != nil {
+		return err
+	}
+	return c.ExtraData.Validate()
+}
+
 // identityPublicKeyClaims holds the claims for a JWT that holds an identity public key.
 type identityPublicKeyClaims struct {
 	jwt.Claims
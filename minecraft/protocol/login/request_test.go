@@ -0,0 +1,201 @@
+package login
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseTampered checks that Parse rejects a login request of which a token was modified after signing.
+func TestParseTampered(t *testing.T) {
+	key := testKey(t)
+	valid := EncodeOffline(testIdentityData(), testClientData(), key)
+	if _, _, _, err := Parse(valid); err != nil {
+		t.Fatalf("parse valid request: %v", err)
+	}
+	req, err := parseLoginRequest(valid)
+	if err != nil {
+		t.Fatalf("parse login request: %v", err)
+	}
+
+	tests := map[string]func(r request) request{
+		"ChainSignature": func(r request) request {
+			r.Chain = chain{tamper(t, r.Chain[0], 2)}
+			return r
+		},
+		"ChainPayload": func(r request) request {
+			r.Chain = chain{tamper(t, r.Chain[0], 1)}
+			return r
+		},
+		"ClientDataSignature": func(r request) request {
+			r.RawToken = tamper(t, r.RawToken, 2)
+			return r
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := modify(*req)
+			if _, _, _, err := Parse(encodeRequest(&r)); err == nil {
+				t.Fatalf("expected an error parsing a tampered request")
+			}
+		})
+	}
+}
+
+// tamper flips a bit in the middle of the part of the JWT passed with the index part, which is 0 for the
+// header, 1 for the payload and 2 for the signature.
+func tamper(t *testing.T, token string, part int) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	b, err := base64.RawURLEncoding.DecodeString(parts[part])
+	if err != nil {
+		t.Fatalf("decode token part %v: %v", part, err)
+	}
+	b[len(b)/2] ^= 1
+	parts[part] = base64.RawURLEncoding.EncodeToString(b)
+	return strings.Join(parts, ".")
+}
+
+// testKey generates a new private key used to sign tokens.
+func testKey(t testing.TB) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+// testIdentityData returns IdentityData of a client that is not logged in to XBOX Live.
+func testIdentityData() IdentityData {
+	return IdentityData{DisplayName: "Steve", Identity: uuid.NewString()}
+}
+
+// testClientData returns ClientData that passes ClientData.Validate.
+func testClientData() ClientData {
+	const width, height = 64, 32
+	return ClientData{
+		DeviceOS:          protocol.DeviceWin10,
+		GameVersion:       protocol.CurrentVersion,
+		LanguageCode:      "en_GB",
+		SelfSignedID:      uuid.NewString(),
+		ServerAddress:     "127.0.0.1:19132",
+		SkinID:            "Standard_Custom",
+		SkinImageWidth:    width,
+		SkinImageHeight:   height,
+		SkinData:          base64.StdEncoding.EncodeToString(make([]byte, width*height*4)),
+		SkinResourcePatch: base64.StdEncoding.EncodeToString([]byte(`{}`)),
+	}
+}
+
+// TestParseTimeWindow checks that Parse rejects login requests with a token that expired or is not yet
+// valid, taking ClockSkew into account.
+func TestParseTimeWindow(t *testing.T) {
+	now := time.Now()
+	valid := jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(time.Hour)), NotBefore: jwt.NewNumericDate(now.Add(-time.Hour))}
+	mojang := valid
+	mojang.Issuer = "Mojang"
+	expired := jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(-ClockSkew - time.Minute))}
+	notYetValid := jwt.Claims{NotBefore: jwt.NewNumericDate(now.Add(ClockSkew + time.Minute))}
+	withinLeeway := jwt.Claims{Expiry: jwt.NewNumericDate(now.Add(-ClockSkew / 2))}
+
+	tests := []struct {
+		name    string
+		request []byte
+		noSkew  bool
+		err     error
+	}{
+		{name: "Valid", request: testOfflineRequest(t, valid)},
+		{name: "Expired", request: testOfflineRequest(t, expired), err: jwt.ErrExpired},
+		{name: "NotYetValid", request: testOfflineRequest(t, notYetValid), err: jwt.ErrNotValidYet},
+		{name: "ExpiredWithinLeeway", request: testOfflineRequest(t, withinLeeway)},
+		{name: "ExpiredWithoutLeeway", request: testOfflineRequest(t, withinLeeway), noSkew: true, err: jwt.ErrExpired},
+		{name: "ChainValid", request: testChain(t, [3]jwt.Claims{valid, mojang, mojang})},
+		{name: "ChainExpired", request: testChain(t, [3]jwt.Claims{valid, {Issuer: "Mojang", Expiry: expired.Expiry}, mojang}), err: jwt.ErrExpired},
+		{name: "ChainNotYetValid", request: testChain(t, [3]jwt.Claims{valid, mojang, {Issuer: "Mojang", NotBefore: notYetValid.NotBefore}}), err: jwt.ErrNotValidYet},
+		// The issuer of the first token must not carry over to the second token, which has no issuer.
+		{name: "ChainClaimsReset", request: testChain(t, [3]jwt.Claims{mojang, valid, mojang}), err: jwt.ErrInvalidIssuer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.noSkew {
+				defer func(skew time.Duration) { ClockSkew = skew }(ClockSkew)
+				ClockSkew = 0
+			}
+			_, _, _, err := Parse(test.request)
+			if test.err == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+// TestParseInvalidIdentityData checks that Parse validates the IdentityData in the login chain.
+func TestParseInvalidIdentityData(t *testing.T) {
+	key := testKey(t)
+	identity := testIdentityData()
+	identity.DisplayName = ""
+	if _, _, _, err := Parse(EncodeOffline(identity, testClientData(), key)); err == nil {
+		t.Fatalf("expected an error parsing a request with an empty display name")
+	}
+}
+
+// testOfflineRequest encodes a login request with a single self-signed token holding the claims passed, like
+// that of a client not logged in to XBOX Live.
+func testOfflineRequest(t *testing.T, claims jwt.Claims) []byte {
+	t.Helper()
+	key := testKey(t)
+	return encodeRequest(&request{
+		Chain: chain{signToken(t, key, identityClaims{
+			Claims:            claims,
+			ExtraData:         testIdentityData(),
+			IdentityPublicKey: MarshalPublicKey(&key.PublicKey),
+		})},
+		RawToken: signToken(t, key, testClientData()),
+	})
+}
+
+// testChain encodes a login request with a chain of three tokens holding the claims passed, like that of a
+// client logged in to XBOX Live. The tokens are signed by keys generated for the test rather than by Mojang,
+// so the client is not authenticated.
+func testChain(t *testing.T, claims [3]jwt.Claims) []byte {
+	t.Helper()
+	client, intermediate, identity := testKey(t), testKey(t), testKey(t)
+	return encodeRequest(&request{
+		Chain: chain{
+			signToken(t, client, identityPublicKeyClaims{Claims: claims[0], IdentityPublicKey: MarshalPublicKey(&intermediate.PublicKey)}),
+			signToken(t, intermediate, identityPublicKeyClaims{Claims: claims[1], IdentityPublicKey: MarshalPublicKey(&identity.PublicKey)}),
+			signToken(t, identity, identityClaims{Claims: claims[2], ExtraData: testIdentityData(), IdentityPublicKey: MarshalPublicKey(&client.PublicKey)}),
+		},
+		RawToken: signToken(t, client, testClientData()),
+	})
+}
+
+// signToken signs a token holding the claims passed using the key passed, with the public key of the key in
+// its x5u header.
+func signToken(t testing.TB, key *ecdsa.PrivateKey, claims any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Key: key, Algorithm: jose.ES384}, &jose.SignerOptions{
+		ExtraHeaders: map[jose.HeaderKey]any{"x5u": MarshalPublicKey(&key.PublicKey)},
+	})
+	if err != nil {
+		t.Fatalf("create signer: %v", err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
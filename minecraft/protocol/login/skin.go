@@ -0,0 +1,146 @@
+package login
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+)
+
+// maxSkinDimension is the maximum width or height of a skin, cape or animation image. It prevents very large
+// allocations and overflows for images with bogus dimensions. Animation images hold all frames of the
+// animation, so they may be considerably larger than the skin itself.
+const maxSkinDimension = 4096
+
+// SkinImage decodes the SkinData of the ClientData into an image using SkinImageWidth and SkinImageHeight as
+// its dimensions. An error is returned if the dimensions are invalid or do not match the length of the data.
+func (data ClientData) SkinImage() (*image.RGBA, error) {
+	img, err := decodeImage(data.SkinData, data.SkinImageWidth, data.SkinImageHeight)
+	if err != nil {
+		return nil, fmt.Errorf("decode skin image: %w", err)
+	}
+	return img, nil
+}
+
+// CapeImage decodes the CapeData of the ClientData into an image using CapeImageWidth and CapeImageHeight as
+// its dimensions. If the ClientData has no cape, a nil image and a nil error are returned. An error is
+// returned if the dimensions are invalid or do not match the length of the data.
+func (data ClientData) CapeImage() (*image.RGBA, error) {
+	if data.CapeData == "" && data.CapeImageWidth == 0 && data.CapeImageHeight == 0 {
+		return nil, nil
+	}
+	img, err := decodeImage(data.CapeData, data.CapeImageWidth, data.CapeImageHeight)
+	if err != nil {
+		return nil, fmt.Errorf("decode cape image: %w", err)
+	}
+	return img, nil
+}
+
+// Geometry decodes the SkinGeometry of the ClientData. If the ClientData has no custom geometry, as is the
+// case for default skins, a zero Geometry and a nil error are returned. Geometry only supports the format
+// used by format version 1.12.0 and up, which the client uses for all skins it sends.
+func (data ClientData) Geometry() (Geometry, error) {
+	var geometry Geometry
+	b, err := base64.StdEncoding.DecodeString(data.SkinGeometry)
+	if err != nil {
+		return geometry, fmt.Errorf("decode skin geometry: decode base64 data: %w", err)
+	}
+	if len(b) == 0 {
+		return geometry, nil
+	}
+	if err := json.Unmarshal(b, &geometry); err != nil {
+		return geometry, fmt.Errorf("decode skin geometry: %w", err)
+	}
+	return geometry, nil
+}
+
+// RGBA decodes the Image of the SkinAnimation into an image using ImageWidth and ImageHeight as its
+// dimensions. The image returned holds all frames of the animation. An error is returned if the dimensions
+// are invalid or do not match the length of the data.
+func (anim SkinAnimation) RGBA() (*image.RGBA, error) {
+	img, err := decodeImage(anim.Image, anim.ImageWidth, anim.ImageHeight)
+	if err != nil {
+		return nil, fmt.Errorf("decode animation image: %w", err)
+	}
+	return img, nil
+}
+
+// decodeImage decodes base64 encoded RGBA ordered pixel data into an image with the width and height passed.
+func decodeImage(base64Data string, width, height int) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 || width > maxSkinDimension || height > maxSkinDimension {
+		return nil, fmt.Errorf("invalid dimensions %vx%v: width and height must be between 1 and %v", width, height, maxSkinDimension)
+	}
+	pix, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64 data: %w", err)
+	}
+	if len(pix) != width*height*4 {
+		return nil, fmt.Errorf("invalid size: got %v, expected %v for dimensions %vx%v", len(pix), width*height*4, width, height)
+	}
+	return &image.RGBA{Pix: pix, Stride: width * 4, Rect: image.Rect(0, 0, width, height)}, nil
+}
+
+// Geometry is the geometry of a skin, as found in the SkinGeometry field of ClientData.
+type Geometry struct {
+	// FormatVersion is the version of the format of the geometry, for example '1.12.0'.
+	FormatVersion string `json:"format_version"`
+	// Models holds the geometry models defined. A skin generally has only one model.
+	Models []GeometryModel `json:"minecraft:geometry"`
+}
+
+// GeometryModel is a single model in a Geometry.
+type GeometryModel struct {
+	// Description holds the identifier and texture properties of the model.
+	Description GeometryDescription `json:"description"`
+	// Bones holds all bones the model consists of.
+	Bones []GeometryBone `json:"bones"`
+}
+
+// GeometryDescription describes a GeometryModel.
+type GeometryDescription struct {
+	// Identifier is the identifier of the model, such as 'geometry.humanoid.custom'. SkinResourcePatch
+	// refers to models by this identifier.
+	Identifier string `json:"identifier"`
+	// TextureWidth and TextureHeight are the dimensions of the texture the UV coordinates of the model refer to.
+	TextureWidth  int `json:"texture_width"`
+	TextureHeight int `json:"texture_height"`
+	// VisibleBoundsWidth, VisibleBoundsHeight and VisibleBoundsOffset describe the bounding box used to
+	// determine if the model is visible.
+	VisibleBoundsWidth  float64    `json:"visible_bounds_width"`
+	VisibleBoundsHeight float64    `json:"visible_bounds_height"`
+	VisibleBoundsOffset [3]float64 `json:"visible_bounds_offset"`
+}
+
+// GeometryBone is a bone of a GeometryModel, such as the head or an arm.
+type GeometryBone struct {
+	// Name is the name of the bone, such as 'head'.
+	Name string `json:"name"`
+	// Parent is the name of the parent bone of the bone. It is empty if the bone has no parent.
+	Parent string `json:"parent,omitempty"`
+	// Pivot is the point the bone rotates around.
+	Pivot [3]float64 `json:"pivot"`
+	// Rotation is the rotation of the bone around the pivot in degrees.
+	Rotation [3]float64 `json:"rotation"`
+	// Mirror specifies if the UV of all cubes of the bone are mirrored.
+	Mirror bool `json:"mirror,omitempty"`
+	// Cubes holds the cubes that make up the bone.
+	Cubes []GeometryCube `json:"cubes"`
+}
+
+// GeometryCube is a single cube of a GeometryBone.
+type GeometryCube struct {
+	// Origin is the position of the corner of the cube with the lowest coordinates.
+	Origin [3]float64 `json:"origin"`
+	// Size is the size of the cube.
+	Size [3]float64 `json:"size"`
+	// Pivot and Rotation are the pivot and rotation of the cube, like those of a GeometryBone.
+	Pivot    [3]float64 `json:"pivot"`
+	Rotation [3]float64 `json:"rotation"`
+	// Inflate is the amount the cube is grown in all directions without changing its UV mapping.
+	Inflate float64 `json:"inflate,omitempty"`
+	// Mirror specifies if the UV of the cube is mirrored.
+	Mirror bool `json:"mirror,omitempty"`
+	// UV holds the UV mapping of the cube. It is either an array of two numbers, holding the UV offset of
+	// the cube in the texture, or an object holding the UV of each face of the cube separately.
+	UV json.RawMessage `json:"uv"`
+}
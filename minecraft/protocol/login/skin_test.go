@@ -0,0 +1,107 @@
+package login
+
+import (
+	"encoding/base64"
+	"math"
+	"testing"
+)
+
+// TestSkinImage checks that SkinImage only decodes skin data with a length that matches the dimensions of the
+// skin, and that it rejects dimensions that are out of bounds.
+func TestSkinImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		size          int
+		valid         bool
+	}{
+		{name: "Valid", width: 64, height: 32, size: 64 * 32 * 4, valid: true},
+		{name: "TooSmall", width: 64, height: 32, size: 64*32*4 - 1},
+		{name: "TooLarge", width: 64, height: 32, size: 64*32*4 + 4},
+		{name: "NoDimensions", width: 0, height: 0, size: 0},
+		{name: "NegativeDimensions", width: -64, height: -32, size: 64 * 32 * 4},
+		{name: "DimensionsTooLarge", width: maxSkinDimension + 1, height: 1, size: (maxSkinDimension + 1) * 4},
+		{name: "DimensionsOverflow", width: math.MaxInt / 2, height: 2, size: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := ClientData{
+				SkinImageWidth:  test.width,
+				SkinImageHeight: test.height,
+				SkinData:        base64.StdEncoding.EncodeToString(make([]byte, test.size)),
+			}
+			img, err := data.SkinImage()
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("expected an error decoding %v bytes of skin data with dimensions %vx%v", test.size, test.width, test.height)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode skin image: %v", err)
+			}
+			if bounds := img.Bounds(); bounds.Dx() != test.width || bounds.Dy() != test.height {
+				t.Fatalf("expected image of %vx%v, got %vx%v", test.width, test.height, bounds.Dx(), bounds.Dy())
+			}
+		})
+	}
+}
+
+// TestCapeImage checks that CapeImage returns no image for ClientData without a cape, and that it rejects
+// cape data that is too large or too small for the dimensions of the cape.
+func TestCapeImage(t *testing.T) {
+	if img, err := (ClientData{}).CapeImage(); img != nil || err != nil {
+		t.Fatalf("expected no image and no error without a cape, got %v and %v", img, err)
+	}
+	for _, size := range []int{64*32*4 - 4, 64*32*4 + 4} {
+		data := ClientData{
+			CapeImageWidth:  64,
+			CapeImageHeight: 32,
+			CapeData:        base64.StdEncoding.EncodeToString(make([]byte, size)),
+		}
+		if _, err := data.CapeImage(); err == nil {
+			t.Errorf("expected an error decoding %v bytes of cape data with dimensions 64x32", size)
+		}
+	}
+}
+
+// TestClientDataValidateImages checks that ClientData.Validate rejects skin, cape and animation data that is
+// too large or too small for the dimensions declared.
+func TestClientDataValidateImages(t *testing.T) {
+	tooSmall := base64.StdEncoding.EncodeToString(make([]byte, 16*16*4-4))
+	tooLarge := base64.StdEncoding.EncodeToString(make([]byte, 16*16*4+4))
+	tests := map[string]func(data *ClientData){
+		"SkinTooSmall": func(data *ClientData) { data.SkinData = tooSmall },
+		"SkinTooLarge": func(data *ClientData) {
+			data.SkinImageWidth, data.SkinImageHeight = 16, 16
+			data.SkinData = tooLarge
+		},
+		"SkinDimensionsTooLarge": func(data *ClientData) {
+			data.SkinImageWidth, data.SkinImageHeight = math.MaxInt/2, 2
+		},
+		"CapeTooSmall": func(data *ClientData) {
+			data.CapeImageWidth, data.CapeImageHeight, data.CapeData = 16, 16, tooSmall
+		},
+		"CapeTooLarge": func(data *ClientData) {
+			data.CapeImageWidth, data.CapeImageHeight, data.CapeData = 16, 16, tooLarge
+		},
+		"AnimationTooSmall": func(data *ClientData) {
+			data.AnimatedImageData = []SkinAnimation{{ImageWidth: 16, ImageHeight: 16, Image: tooSmall}}
+		},
+		"AnimationTooLarge": func(data *ClientData) {
+			data.AnimatedImageData = []SkinAnimation{{ImageWidth: 16, ImageHeight: 16, Image: tooLarge}}
+		},
+	}
+	if err := testClientData().Validate(); err != nil {
+		t.Fatalf("validate client data: %v", err)
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := testClientData()
+			modify(&data)
+			if err := data.Validate(); err == nil {
+				t.Fatalf("expected an error validating client data")
+			}
+		})
+	}
+}
@@ -20,6 +20,17 @@ type Compression interface {
 	Decompress(compressed []byte) ([]byte, error)
 }
 
+// LimitedDecompressor may be implemented by a Compression to stop decompressing data once the decompressed
+// data grows too large. If a Compression implements it, the Decoder uses DecompressLimit rather than
+// Decompress when a maximum batch size is set, so that a small batch cannot expand to an arbitrary size
+// before its size is checked.
+type LimitedDecompressor interface {
+	// DecompressLimit decompresses the given data like Decompress. If limit is larger than 0, an error is
+	// returned as soon as the decompressed data exceeds limit bytes, without decompressing the rest of the
+	// data.
+	DecompressLimit(compressed []byte, limit int) ([]byte, error)
+}
+
 var (
 	// NopCompression is an empty implementation that does not compress data.
 	NopCompression nopCompression
@@ -67,7 +78,15 @@ func (nopCompression) Compress(decompressed []byte) ([]byte, error) {
 }
 
 // Decompress ...
-func (nopCompression) Decompress(compressed []byte) ([]byte, error) {
+func (c nopCompression) Decompress(compressed []byte) ([]byte, error) {
+	return c.DecompressLimit(compressed, 0)
+}
+
+// DecompressLimit ...
+func (nopCompression) DecompressLimit(compressed []byte, limit int) ([]byte, error) {
+	if limit > 0 && len(compressed) > limit {
+		return nil, errDecompressedLimit(limit)
+	}
 	return compressed, nil
 }
 
@@ -102,7 +121,12 @@ func (flateCompression) Compress(decompressed []byte) ([]byte, error) {
 }
 
 // Decompress ...
-func (flateCompression) Decompress(compressed []byte) ([]byte, error) {
+func (c flateCompression) Decompress(compressed []byte) ([]byte, error) {
+	return c.DecompressLimit(compressed, 0)
+}
+
+// DecompressLimit ...
+func (flateCompression) DecompressLimit(compressed []byte, limit int) ([]byte, error) {
 	buf := bytes.NewReader(compressed)
 	c := flateDecompressPool.Get().(io.ReadCloser)
 	defer flateDecompressPool.Put(c)
@@ -113,10 +137,21 @@ func (flateCompression) Decompress(compressed []byte) ([]byte, error) {
 	_ = c.Close()
 
 	// Guess an uncompressed size of 2*len(compressed).
-	decompressed := bytes.NewBuffer(make([]byte, 0, len(compressed)*2))
-	if _, err := io.Copy(decompressed, c); err != nil {
+	guess := len(compressed) * 2
+	var r io.Reader = c
+	if limit > 0 {
+		guess = min(guess, limit)
+		// Read at most one byte more than the limit, so that decompression stops as soon as the limit is
+		// exceeded and a small payload cannot expand to an arbitrary size.
+		r = io.LimitReader(c, int64(limit)+1)
+	}
+	decompressed := bytes.NewBuffer(make([]byte, 0, guess))
+	if _, err := io.Copy(decompressed, r); err != nil {
 		return nil, fmt.Errorf("decompress flate: %w", err)
 	}
+	if limit > 0 && decompressed.Len() > limit {
+		return nil, errDecompressedLimit(limit)
+	}
 	return decompressed.Bytes(), nil
 }
 
@@ -135,10 +170,23 @@ func (snappyCompression) Compress(decompressed []byte) ([]byte, error) {
 }
 
 // Decompress ...
-func (snappyCompression) Decompress(compressed []byte) ([]byte, error) {
+func (c snappyCompression) Decompress(compressed []byte) ([]byte, error) {
+	return c.DecompressLimit(compressed, 0)
+}
+
+// DecompressLimit ...
+func (snappyCompression) DecompressLimit(compressed []byte, limit int) ([]byte, error) {
 	// Snappy writes a decoded data length prefix, so it can allocate the
 	// perfect size right away and only needs to allocate once. No need to pool
-	// byte slices here either.
+	// byte slices here either. The prefix is checked against the limit before
+	// anything is allocated.
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("decompress snappy: %w", err)
+	}
+	if limit > 0 && n > limit {
+		return nil, errDecompressedLimit(limit)
+	}
 	decompressed, err := snappy.Decode(nil, compressed)
 	if err != nil {
 		return nil, fmt.Errorf("decompress snappy: %w", err)
@@ -146,6 +194,11 @@ func (snappyCompression) Decompress(compressed []byte) ([]byte, error) {
 	return decompressed, nil
 }
 
+// errDecompressedLimit returns an error for decompressed data that exceeds the limit passed.
+func errDecompressedLimit(limit int) error {
+	return fmt.Errorf("decompressed size exceeds max=%v", limit)
+}
+
 // init registers all valid compressions with the protocol.
 func init() {
 	RegisterCompression(flateCompression{})
@@ -0,0 +1,118 @@
+package packet_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// TestDecodeCompressionBomb checks that a small compressed batch that expands to far more than the maximum
+// batch size is rejected without decompressing all of it.
+func TestDecodeCompressionBomb(t *testing.T) {
+	const (
+		maxBatchSize = 1 << 20
+		bombSize     = 32 << 20
+	)
+	buf := new(bytes.Buffer)
+	enc := packet.NewEncoder(buf)
+	enc.EnableCompression(packet.FlateCompression)
+	if err := enc.Encode([][]byte{make([]byte, bombSize)}); err != nil {
+		t.Fatalf("encode batch: %v", err)
+	}
+	if buf.Len() >= maxBatchSize {
+		t.Fatalf("compressed batch of %v bytes is not small enough to test with", buf.Len())
+	}
+
+	dec := packet.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.EnableCompression()
+	dec.SetMaxBatchSize(maxBatchSize)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := dec.Decode()
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatalf("expected an error decoding a batch that decompresses to %v bytes", bombSize)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4*maxBatchSize {
+		t.Errorf("decoding allocated %v bytes, expected at most %v", allocated, 4*maxBatchSize)
+	}
+}
+
+// TestCompressionTransition encodes and decodes batches while compression is enabled and disabled between
+// them, like a connection does after NetworkSettings are exchanged. Encryption is enabled halfway, as it is
+// after the handshake. Every batch must be decoded with the compression state that it was encoded with.
+func TestCompressionTransition(t *testing.T) {
+	var key [32]byte
+	_, _ = rand.Read(key[:])
+	steps := []struct {
+		name                string
+		compress, encrypted bool
+	}{
+		{name: "NetworkSettings"},
+		{name: "Login", compress: true},
+		{name: "Handshake", compress: true, encrypted: true},
+		{name: "Uncompressed", encrypted: true},
+		{name: "Recompressed", compress: true, encrypted: true},
+	}
+
+	enc := packet.NewEncoder(nil)
+	readers := make([]io.Reader, len(steps))
+	for i, step := range steps {
+		if step.compress {
+			enc.EnableCompression(packet.FlateCompression)
+		} else {
+			enc.DisableCompression()
+		}
+		if step.encrypted && !enc.EncryptionEnabled() {
+			enc.EnableEncryption(key)
+		}
+		buf := new(bytes.Buffer)
+		enc.SetWriter(buf)
+		if err := enc.Encode(transitionPackets(i)); err != nil {
+			t.Fatalf("%v: encode batch: %v", step.name, err)
+		}
+		if !step.encrypted {
+			// The second byte of a compressed batch is the ID of the compression algorithm, whereas that of
+			// an uncompressed batch is the length of its first packet.
+			if first := buf.Bytes()[1]; (first == byte(packet.FlateCompression.EncodeCompression())) != step.compress {
+				t.Fatalf("%v: unexpected second byte %x of batch, compressed: %v", step.name, first, step.compress)
+			}
+		}
+		readers[i] = buf
+	}
+
+	dec := packet.NewDecoder(io.MultiReader(readers...))
+	for i, step := range steps {
+		if step.compress {
+			dec.EnableCompression()
+		} else {
+			dec.DisableCompression()
+		}
+		if step.encrypted && i > 0 && !steps[i-1].encrypted {
+			dec.EnableEncryption(key)
+		}
+		pks, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("%v: decode batch: %v", step.name, err)
+		}
+		expected := transitionPackets(i)
+		if len(pks) != len(expected) {
+			t.Fatalf("%v: expected %v packets, got %v", step.name, len(expected), len(pks))
+		}
+		for j := range pks {
+			if !bytes.Equal(pks[j], expected[j]) {
+				t.Fatalf("%v: packet %v: expected %q, got %q", step.name, j, expected[j], pks[j])
+			}
+		}
+	}
+}
+
+// transitionPackets returns the packets of batch i encoded in TestCompressionTransition.
+func transitionPackets(i int) [][]byte {
+	return [][]byte{[]byte(fmt.Sprintf("batch %v", i)), bytes.Repeat([]byte{byte(i)}, 600)}
+}
@@ -25,6 +25,8 @@ type Decoder struct {
 	encrypt    *encrypt
 
 	checkPacketLimit bool
+	// maxBatchSize is the maximum size of a batch after decompression. If 0, the size is not limited.
+	maxBatchSize int
 }
 
 // packetReader is used to read packets immediately instead of copying them in a buffer first. This is a
@@ -60,12 +62,27 @@ func (decoder *Decoder) EnableCompression() {
 	decoder.decompress = true
 }
 
+// DisableCompression disables compression for the Decoder, so that batches decoded after the call are no
+// longer decompressed.
+func (decoder *Decoder) DisableCompression() {
+	decoder.decompress = false
+}
+
 // DisableBatchPacketLimit disables the check that limits the number of packets allowed in a single packet
 // batch. This should typically be called for Decoders decoding from a server connection.
 func (decoder *Decoder) DisableBatchPacketLimit() {
 	decoder.checkPacketLimit = false
 }
 
+// SetMaxBatchSize sets the maximum size in bytes of a batch after it is decompressed. Decode returns an error
+// for batches that exceed this size. By default, or if n is 0 or lower, the size of batches is not limited.
+// This should typically be set for Decoders decoding from a client connection, so that a client cannot make
+// the server use large amounts of memory by sending large batches. Decompression is stopped as soon as the
+// size is exceeded, so that small compressed batches that expand enormously are rejected cheaply.
+func (decoder *Decoder) SetMaxBatchSize(n int) {
+	decoder.maxBatchSize = max(n, 0)
+}
+
 const (
 	// header is the header of compressed 'batches' from Minecraft.
 	header = 0xfe
@@ -76,12 +93,16 @@ const (
 
 // Decode decodes one 'packet' from the io.Reader passed in NewDecoder(), producing a slice of packets that it
 // held and an error if not successful.
+// The byte slices returned are never reused by the Decoder, so they may be held by the caller after the next
+// call to Decode, for example to process them in another goroutine.
 func (decoder *Decoder) Decode() (packets [][]byte, err error) {
 	var data []byte
 	if decoder.pr == nil {
 		var n int
 		n, err = decoder.r.Read(decoder.buf)
-		data = decoder.buf[:n]
+		// The packets returned may point into data if the batch is not compressed, so data must be copied
+		// out of the buffer that is reused for the next read.
+		data = bytes.Clone(decoder.buf[:n])
 	} else {
 		data, err = decoder.pr.ReadPacket()
 	}
@@ -112,12 +133,20 @@ func (decoder *Decoder) Decode() (packets [][]byte, err error) {
 			if !ok {
 				return nil, fmt.Errorf("decompress batch: unknown compression algorithm %v", data[0])
 			}
-			data, err = compression.Decompress(data[1:])
+			if limiter, ok := compression.(LimitedDecompressor); ok && decoder.maxBatchSize > 0 {
+				data, err = limiter.DecompressLimit(data[1:], decoder.maxBatchSize)
+			} else {
+				// The size of the decompressed data is still checked against the maximum batch size below.
+				data, err = compression.Decompress(data[1:])
+			}
 			if err != nil {
 				return nil, fmt.Errorf("decompress batch: %w", err)
 			}
 		}
 	}
+	if decoder.maxBatchSize > 0 && len(data) > decoder.maxBatchSize {
+		return nil, fmt.Errorf("decode batch: size %v exceeds max=%v", len(data), decoder.maxBatchSize)
+	}
 
 	b := bytes.NewBuffer(data)
 	for b.Len() != 0 {
@@ -0,0 +1,81 @@
+package packet_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"io"
+	"testing"
+)
+
+// TestDecodeHoldPackets checks that the packets returned by Decode remain unchanged while later batches are
+// decoded, both for uncompressed batches, of which the packets point into the data read, and for compressed
+// batches. The packets are checked in another goroutine once all batches were decoded.
+func TestDecodeHoldPackets(t *testing.T) {
+	const batches, perBatch = 200, 5
+	compressions := map[string]packet.Compression{"None": nil, "Flate": packet.FlateCompression, "Snappy": packet.SnappyCompression}
+	for name, compression := range compressions {
+		t.Run(name, func(t *testing.T) {
+			enc := packet.NewEncoder(nil)
+			if compression != nil {
+				enc.EnableCompression(compression)
+			}
+			readers := make([]io.Reader, batches)
+			for i := range readers {
+				buf := new(bytes.Buffer)
+				enc.SetWriter(buf)
+				pks := make([][]byte, perBatch)
+				for j := range pks {
+					pks[j] = holdPacket(i, j)
+				}
+				if err := enc.Encode(pks); err != nil {
+					t.Fatalf("encode batch %v: %v", i, err)
+				}
+				readers[i] = buf
+			}
+
+			// A bytes.Buffer wrapped in io.MultiReader is not a packetReader, so the Decoder reads batches
+			// into the buffer it reuses for every read.
+			dec := packet.NewDecoder(io.MultiReader(readers...))
+			if compression != nil {
+				dec.EnableCompression()
+			}
+			held := make(chan [][]byte, batches)
+			for i := 0; i < batches; i++ {
+				pks, err := dec.Decode()
+				if err != nil {
+					t.Fatalf("decode batch %v: %v", i, err)
+				}
+				held <- pks
+			}
+			close(held)
+
+			done := make(chan error)
+			go func() {
+				defer close(done)
+				i := 0
+				for pks := range held {
+					if len(pks) != perBatch {
+						done <- fmt.Errorf("batch %v: expected %v packets, got %v", i, perBatch, len(pks))
+						return
+					}
+					for j, pk := range pks {
+						if expected := holdPacket(i, j); !bytes.Equal(pk, expected) {
+							done <- fmt.Errorf("batch %v packet %v: expected %q, got %q", i, j, expected, pk)
+							return
+						}
+					}
+					i++
+				}
+			}()
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// holdPacket returns the content of packet j of batch i used in TestDecodeHoldPackets.
+func holdPacket(i, j int) []byte {
+	return bytes.Repeat([]byte(fmt.Sprintf("batch %v packet %v;", i, j)), j+1)
+}
@@ -16,6 +16,9 @@ type Encoder struct {
 
 	compression Compression
 	encrypt     *encrypt
+
+	// maxBatchBytes is the maximum size of a batch returned by Split. If 0, the size is not limited.
+	maxBatchBytes int
 }
 
 // NewEncoder returns a new Encoder for the io.Writer passed. Each final packet produced by the Encoder is
@@ -24,6 +27,16 @@ func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{w: w}
 }
 
+// SetWriter sets the io.Writer that the Encoder writes batches to, replacing the io.Writer passed to
+// NewEncoder. It may be used to change the transport of a connection, or to wrap the io.Writer to change the
+// framing of batches. Compression and encryption state is kept, so the new io.Writer must lead to the same
+// peer. The Encoder does not synchronise calls to its methods, so SetWriter must not be called while a call
+// to Encode or EncodeN is in progress. Callers writing from multiple goroutines must guard both with the
+// same lock.
+func (encoder *Encoder) SetWriter(w io.Writer) {
+	encoder.w = w
+}
+
 // EnableEncryption enables encryption for the Encoder using the secret key bytes passed. Each packet sent
 // after encryption is enabled will be encrypted.
 func (encoder *Encoder) EnableEncryption(keyBytes [32]byte) {
@@ -33,14 +46,63 @@ func (encoder *Encoder) EnableEncryption(keyBytes [32]byte) {
 	encoder.encrypt = newEncrypt(keyBytes[:], stream)
 }
 
+// EncryptionEnabled checks if encryption was enabled for the Encoder using EnableEncryption.
+func (encoder *Encoder) EncryptionEnabled() bool {
+	return encoder.encrypt != nil
+}
+
 // EnableCompression enables compression for the Encoder.
 func (encoder *Encoder) EnableCompression(compression Compression) {
 	encoder.compression = compression
 }
 
+// DisableCompression disables compression for the Encoder, so that batches encoded after the call are no
+// longer compressed. The peer must disable compression for its Decoder at the same point in the stream.
+func (encoder *Encoder) DisableCompression() {
+	encoder.compression = nil
+}
+
+// SetMaxBatchBytes sets the maximum size in bytes of a batch, before compression, that Split groups packets
+// into. By default, or if n is 0 or lower, all packets are grouped into a single batch.
+func (encoder *Encoder) SetMaxBatchBytes(n int) {
+	encoder.maxBatchBytes = max(n, 0)
+}
+
+// Split splits the packets passed into batches that each hold at most the maximum amount of bytes set using
+// SetMaxBatchBytes, so that each batch may be passed to a call to Encode. The order of the packets is
+// preserved. A packet that is larger than the maximum by itself is put in a batch of its own. If no maximum
+// is set, a single batch holding all packets is returned.
+func (encoder *Encoder) Split(packets [][]byte) [][][]byte {
+	if encoder.maxBatchBytes <= 0 {
+		return [][][]byte{packets}
+	}
+	var (
+		batches [][][]byte
+		start   int
+		size    int
+	)
+	for i, packet := range packets {
+		// Each packet is prefixed with its length, which takes up at most 5 bytes.
+		n := len(packet) + 5
+		if i > start && size+n > encoder.maxBatchBytes {
+			batches = append(batches, packets[start:i:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(batches, packets[start:])
+}
+
 // Encode encodes the packets passed. It writes all of them as a single packet which is  compressed and
 // optionally encrypted.
 func (encoder *Encoder) Encode(packets [][]byte) error {
+	_, err := encoder.EncodeN(packets)
+	return err
+}
+
+// EncodeN encodes the packets passed like Encode, but also returns the amount of bytes written to the
+// underlying io.Writer. This is the size of the batch after compression and encryption.
+func (encoder *Encoder) EncodeN(packets [][]byte) (int, error) {
 	buf := internal.BufferPool.Get().(*bytes.Buffer)
 	defer func() {
 		// Reset the buffer, so we can return it to the buffer pool safely.
@@ -52,10 +114,10 @@ func (encoder *Encoder) Encode(packets [][]byte) error {
 	for _, packet := range packets {
 		// Each packet is prefixed with a varuint32 specifying the length of the packet.
 		if err := writeVaruint32(buf, uint32(len(packet)), l); err != nil {
-			return fmt.Errorf("encode batch: write packet length: %w", err)
+			return 0, fmt.Errorf("encode batch: write packet length: %w", err)
 		}
 		if _, err := buf.Write(packet); err != nil {
-			return fmt.Errorf("encode batch: write packet payload: %w", err)
+			return 0, fmt.Errorf("encode batch: write packet payload: %w", err)
 		}
 	}
 
@@ -66,7 +128,7 @@ func (encoder *Encoder) Encode(packets [][]byte) error {
 		var err error
 		data, err = encoder.compression.Compress(data)
 		if err != nil {
-			return fmt.Errorf("compress batch: %w", err)
+			return 0, fmt.Errorf("compress batch: %w", err)
 		}
 	}
 
@@ -76,10 +138,11 @@ func (encoder *Encoder) Encode(packets [][]byte) error {
 		// compressed data of this packet.
 		data = encoder.encrypt.encrypt(data)
 	}
-	if _, err := encoder.w.Write(data); err != nil {
-		return fmt.Errorf("write batch: %w", err)
+	n, err := encoder.w.Write(data)
+	if err != nil {
+		return n, fmt.Errorf("write batch: %w", err)
 	}
-	return nil
+	return n, nil
 }
 
 // writeVaruint32 writes a uint32 to the destination buffer passed with a size of 1-5 bytes. It uses byte
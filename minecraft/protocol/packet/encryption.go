@@ -1,13 +1,19 @@
 package packet
 
 import (
-	"bytes"
 	"crypto/cipher"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/binary"
+	"errors"
 	"fmt"
 )
 
+// ErrInvalidChecksum is returned, wrapped, by Decoder.Decode if the checksum of an encrypted batch does not
+// match the checksum computed for it. The checksum covers the send counter of the batch, so a mismatch
+// means that the batch was corrupted or tampered with, or that batches were replayed, dropped or reordered.
+var ErrInvalidChecksum = errors.New("invalid checksum")
+
 // encrypt holds an encryption session with several fields required to encrypt and/or decrypt incoming
 // packets. It may be initialised using secret key bytes computed using the shared secret produced with a
 // private and a public ECDSA key.
@@ -69,8 +75,8 @@ func (encrypt *encrypt) verify(data []byte) error {
 	ourSum := hash.Sum(nil)[:8]
 
 	// Finally we check if the original sum was equal to the sum we just produced.
-	if !bytes.Equal(sum, ourSum) {
-		return fmt.Errorf("invalid checksum of packet %v: expected %x, got %x", encrypt.sendCounter-1, ourSum, sum)
+	if subtle.ConstantTimeCompare(sum, ourSum) != 1 {
+		return fmt.Errorf("%w of packet %v: expected %x, got %x", ErrInvalidChecksum, encrypt.sendCounter-1, ourSum, sum)
 	}
 	return nil
 }
@@ -0,0 +1,41 @@
+package packet_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"io"
+	"testing"
+)
+
+// TestDecodeInvalidChecksum checks that an encrypted batch of which a byte was modified is rejected with an
+// error wrapping ErrInvalidChecksum, after a valid batch was decoded using the same keys.
+func TestDecodeInvalidChecksum(t *testing.T) {
+	var key [32]byte
+	_, _ = rand.Read(key[:])
+	enc := packet.NewEncoder(nil)
+	enc.EnableEncryption(key)
+
+	batches := make([][]byte, 2)
+	for i := range batches {
+		buf := new(bytes.Buffer)
+		enc.SetWriter(buf)
+		if err := enc.Encode([][]byte{[]byte("packet payload")}); err != nil {
+			t.Fatalf("encode batch %v: %v", i, err)
+		}
+		batches[i] = buf.Bytes()
+	}
+	// Flip a byte of the second batch, leaving its header intact.
+	tampered := batches[1]
+	tampered[len(tampered)/2] ^= 1
+
+	dec := packet.NewDecoder(io.MultiReader(bytes.NewReader(batches[0]), bytes.NewReader(tampered)))
+	dec.EnableEncryption(key)
+	if pks, err := dec.Decode(); err != nil || len(pks) != 1 || string(pks[0]) != "packet payload" {
+		t.Fatalf("decode valid batch: expected one packet, got %q and error %v", pks, err)
+	}
+	if _, err := dec.Decode(); !errors.Is(err, packet.ErrInvalidChecksum) {
+		t.Fatalf("decode tampered batch: expected error wrapping %v, got %v", packet.ErrInvalidChecksum, err)
+	}
+}
@@ -0,0 +1,122 @@
+package packet_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"maps"
+	"math"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// maxDecodeAlloc is the maximum amount of bytes that decoding a single fuzzed payload may allocate. Length
+// prefixes are checked against the bytes left in the payload, so payloads of the size generated by the fuzzer
+// never need nearly this much.
+const maxDecodeAlloc = 16 << 20
+
+// FuzzPacketDecode decodes random payloads as packets of the client and server pools, like a Conn does for
+// packets received from the other end of a connection. Decoding a malformed payload must only fail with an
+// error, which the protocol.Reader reports by panicking with it, never with a runtime error such as an index
+// out of range, and must not allocate much more memory than the payload holds. The seed corpus in testdata/fuzz holds payloads of real packets, and every packet of the pools
+// is additionally seeded with an empty payload and with payloads starting with huge length prefixes.
+// The fuzzer is run using 'go test -fuzz FuzzPacketDecode ./minecraft/protocol/packet'.
+func FuzzPacketDecode(f *testing.F) {
+	pool := packet.NewServerPool()
+	maps.Copy(pool, packet.NewClientPool())
+	ids := make([]uint32, 0, len(pool))
+	for id := range pool {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	// Payloads that start with a huge length prefix are seeded for every packet too, so that the length checks
+	// of the protocol.Reader are covered for packets that start with a string or slice.
+	var hugeLengths [][]byte
+	for _, l := range []uint64{math.MaxUint32, 1 << 31} {
+		hugeLengths = append(hugeLengths, binary.AppendUvarint(nil, l), binary.LittleEndian.AppendUint32(nil, uint32(l)))
+	}
+	for _, id := range ids {
+		f.Add(id, []byte{})
+		for _, prefix := range hugeLengths {
+			f.Add(id, append(prefix, 1, 2, 3, 4))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, id uint32, payload []byte) {
+		pkFunc, ok := pool[id]
+		if !ok {
+			// Most IDs are not in the pool, so they are mapped onto one that is, rather than being wasted.
+			pkFunc = pool[ids[id%uint32(len(ids))]]
+		}
+		pk := pkFunc()
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(runtime.Error); ok {
+				t.Fatalf("decode %T: runtime error: %v", pk, err)
+			}
+			if _, ok := recovered.(error); !ok {
+				t.Fatalf("decode %T: panic with non-error value: %v", pk, recovered)
+			}
+		}()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		pk.Marshal(protocol.NewReader(bytes.NewBuffer(payload), 0, true))
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > maxDecodeAlloc {
+			t.Fatalf("decode %T: %v bytes allocated for a payload of %v bytes", pk, allocated, len(payload))
+		}
+	})
+}
+
+// FuzzHeader reads a Header from random data and writes random Headers. A Header read successfully must be
+// written and read back unchanged, and a Header that fails to be read must be left unchanged. Writing a
+// Header must only fail if one of its fields is out of bounds.
+// The fuzzer is run using 'go test -fuzz FuzzHeader ./minecraft/protocol/packet'.
+func FuzzHeader(f *testing.F) {
+	f.Add([]byte{}, uint32(0), byte(0), byte(0))
+	// A truncated varuint32 and a varuint32 with bits beyond the sub client IDs set.
+	f.Add([]byte{0x80}, uint32(0x3FF), byte(3), byte(3))
+	f.Add([]byte{0xff, 0xff, 0x03}, uint32(0x400), byte(4), byte(0))
+	f.Add([]byte{0x8f, 0x30}, uint32(packet.IDText), byte(1), byte(2))
+
+	f.Fuzz(func(t *testing.T, data []byte, id uint32, sender, target byte) {
+		h := packet.Header{PacketID: 1, SenderSubClient: 2, TargetSubClient: 3}
+		if err := h.Read(bytes.NewReader(data)); err != nil {
+			if h != (packet.Header{PacketID: 1, SenderSubClient: 2, TargetSubClient: 3}) {
+				t.Fatalf("header changed after failed read of %x: %+v", data, h)
+			}
+		} else {
+			roundTripHeader(t, h)
+		}
+
+		h = packet.Header{PacketID: id, SenderSubClient: sender, TargetSubClient: target}
+		valid := id <= 0x3FF && sender <= 3 && target <= 3
+		if err := h.Write(new(bytes.Buffer)); (err == nil) != valid {
+			t.Fatalf("write %+v: expected success %v, got error %v", h, valid, err)
+		}
+		if valid {
+			roundTripHeader(t, h)
+		}
+	})
+}
+
+// roundTripHeader writes the Header passed and checks that reading it back produces the same Header.
+func roundTripHeader(t *testing.T, h packet.Header) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := h.Write(buf); err != nil {
+		t.Fatalf("write %+v: %v", h, err)
+	}
+	var read packet.Header
+	if err := read.Read(buf); err != nil {
+		t.Fatalf("read %+v back: %v", h, err)
+	}
+	if read != h || buf.Len() != 0 {
+		t.Fatalf("read %+v back as %+v with %v bytes left", h, read, buf.Len())
+	}
+}
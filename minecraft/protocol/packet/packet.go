@@ -1,6 +1,8 @@
 package packet
 
 import (
+	"errors"
+	"fmt"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"io"
 )
@@ -26,17 +28,33 @@ type Header struct {
 	TargetSubClient byte
 }
 
-// Write writes the header as a single varuint32 to buf.
+// Write writes the header as a single varuint32 to buf. An error is returned if the packet ID does not fit in
+// 10 bits or if either of the sub client IDs does not fit in 2 bits, as the header could then not be read
+// back.
 func (header *Header) Write(w io.ByteWriter) error {
+	if header.PacketID > 0x3FF {
+		return fmt.Errorf("write packet header: packet ID %v exceeds maximum of %v", header.PacketID, 0x3FF)
+	}
+	if header.SenderSubClient > 0x3 || header.TargetSubClient > 0x3 {
+		return fmt.Errorf("write packet header: sub client IDs %v and %v must not exceed %v", header.SenderSubClient, header.TargetSubClient, 0x3)
+	}
 	return protocol.WriteVaruint32(w, header.PacketID|(uint32(header.SenderSubClient)<<10)|(uint32(header.TargetSubClient)<<12))
 }
 
-// Read reads a varuint32 from buf and sets the corresponding values to the Header.
+// Read reads a varuint32 from buf and sets the corresponding values to the Header. If an error is returned,
+// the Header is left unchanged.
 func (header *Header) Read(r io.ByteReader) error {
 	var value uint32
 	if err := protocol.Varuint32(r, &value); err != nil {
+		if errors.Is(err, io.EOF) {
+			// A packet without (complete) header is never valid, so we don't return io.EOF here.
+			return fmt.Errorf("truncated header: %w", io.ErrUnexpectedEOF)
+		}
 		return err
 	}
+	if value>>14 != 0 {
+		return fmt.Errorf("invalid header %v: bits beyond the packet ID and sub client IDs are set", value)
+	}
 	header.PacketID = value & 0x3FF
 	header.SenderSubClient = byte((value >> 10) & 0x3)
 	header.TargetSubClient = byte((value >> 12) & 0x3)
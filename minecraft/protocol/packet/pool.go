@@ -1,5 +1,7 @@
 package packet
 
+import "slices"
+
 // RegisterPacketFromClient registers a function that returns a packet for a
 // specific ID. Packets with this ID coming in from connections will resolve to
 // the packet returned by the function passed. noinspection
@@ -23,6 +25,25 @@ var packetsFromServer = map[uint32]func() Packet{}
 // Pool is a map holding packets indexed by a packet ID.
 type Pool map[uint32]func() Packet
 
+// Register registers a function that returns a packet for a specific ID in the Pool. Packets with this ID
+// looked up in the Pool resolve to the packet returned by the function passed, overwriting any packet
+// previously registered with the same ID.
+func (p Pool) Register(id uint32, pk func() Packet) {
+	p[id] = pk
+}
+
+// IDs returns the IDs of all packets registered in the Pool, sorted in ascending order. Packets read with an
+// ID not returned are decoded as an *Unknown packet. The type of the packet registered for an ID may be
+// obtained by calling the function it is registered with, such as fmt.Sprintf("%T", p[id]()).
+func (p Pool) IDs() []uint32 {
+	ids := make([]uint32, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
 // NewClientPool returns a new pool containing packets sent by a client.
 // Packets may be retrieved from it simply by indexing it with the packet ID.
 func NewClientPool() Pool {
@@ -8,10 +8,15 @@ import (
 // Unknown is an implementation of the Packet interface for unknown/unimplemented packets. It holds the packet
 // ID and the raw payload. It serves as a way to read raw unknown packets and forward them to another
 // connection, without necessarily implementing them.
+// An Unknown read from a connection holds all bytes of the packet that follow its header, so writing it to
+// another connection produces exactly the same bytes as were read. The sub-client IDs in the header are not
+// held by Unknown, but may be obtained and forwarded using minecraft.Conn's ReadPacketHeader and
+// WritePacketTo methods.
 type Unknown struct {
 	// PacketID is the packet ID of the packet.
 	PacketID uint32
-	// Payload is the raw payload of the packet.
+	// Payload is the raw payload of the packet, excluding the packet header. It holds every byte of the
+	// packet following the header, unmodified.
 	Payload []byte
 }
 
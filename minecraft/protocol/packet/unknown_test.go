@@ -0,0 +1,62 @@
+package packet_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"testing"
+)
+
+// TestUnknownRoundTrip checks that packets decoded as an Unknown are encoded to exactly the same batch as
+// the one they were decoded from, so that they may be forwarded without being implemented.
+func TestUnknownRoundTrip(t *testing.T) {
+	pks := []packet.Packet{
+		&packet.Text{TextType: packet.TextTypeChat, SourceName: "Steve", Message: "Hello", XUID: "1234"},
+		&packet.MovePlayer{EntityRuntimeID: 1, Position: [3]float32{1, 2, 3}, Mode: packet.MoveModeTeleport},
+		&packet.ClientToServerHandshake{},
+		&packet.Unknown{PacketID: 0x3FF, Payload: []byte{0, 1, 2, 0xff}},
+	}
+	for _, pk := range pks {
+		t.Run(fmt.Sprintf("%T", pk), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			h := packet.Header{PacketID: pk.ID(), SenderSubClient: 1, TargetSubClient: 2}
+			if err := h.Write(buf); err != nil {
+				t.Fatalf("write header: %v", err)
+			}
+			pk.Marshal(protocol.NewWriter(buf, 0))
+			batch := encodeBatch(t, buf.Bytes())
+
+			data, err := packet.NewDecoder(bytes.NewReader(batch)).Decode()
+			if err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			r := bytes.NewBuffer(data[0])
+			var readHeader packet.Header
+			if err := readHeader.Read(r); err != nil {
+				t.Fatalf("read header: %v", err)
+			}
+			unknown := &packet.Unknown{PacketID: readHeader.PacketID}
+			unknown.Marshal(protocol.NewReader(r, 0, false))
+
+			buf.Reset()
+			if err := readHeader.Write(buf); err != nil {
+				t.Fatalf("write header: %v", err)
+			}
+			unknown.Marshal(protocol.NewWriter(buf, 0))
+			if forwarded := encodeBatch(t, buf.Bytes()); !bytes.Equal(forwarded, batch) {
+				t.Fatalf("forwarded batch differs:\nexpected %x\ngot      %x", batch, forwarded)
+			}
+		})
+	}
+}
+
+// encodeBatch encodes a batch holding the single packet passed without compression or encryption.
+func encodeBatch(t *testing.T, pk []byte) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := packet.NewEncoder(buf).Encode([][]byte{pk}); err != nil {
+		t.Fatalf("encode batch: %v", err)
+	}
+	return buf.Bytes()
+}
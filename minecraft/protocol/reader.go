@@ -25,14 +25,30 @@ type Reader struct {
 	}
 	shieldID      int32
 	limitsEnabled bool
+	maxLength     uint32
 }
 
+// DefaultMaxLength is the maximum length of strings, byte slices and slices read by a Reader, unless changed
+// using Reader.SetMaxLength.
+const DefaultMaxLength = math.MaxInt32
+
 // NewReader creates a new Reader using the io.ByteReader passed as underlying source to read bytes from.
 func NewReader(r interface {
 	io.Reader
 	io.ByteReader
 }, shieldID int32, enableLimits bool) *Reader {
-	return &Reader{r: r, shieldID: shieldID, limitsEnabled: enableLimits}
+	return &Reader{r: r, shieldID: shieldID, limitsEnabled: enableLimits, maxLength: DefaultMaxLength}
+}
+
+// SetMaxLength sets the maximum length of strings, byte slices and slices read by the Reader. If a length
+// prefix read is larger than n, the Reader panics before allocating any memory. If n is 0, the maximum
+// length is reset to DefaultMaxLength. Regardless of the maximum length, the Reader also panics if a length
+// exceeds the amount of bytes left in the underlying buffer.
+func (r *Reader) SetMaxLength(n uint32) {
+	if n == 0 {
+		n = DefaultMaxLength
+	}
+	r.maxLength = min(n, DefaultMaxLength)
 }
 
 // Uint8 reads a uint8 from the underlying buffer.
@@ -60,21 +76,14 @@ func (r *Reader) Bool(x *bool) {
 	*x = *(*bool)(unsafe.Pointer(&u))
 }
 
-// errStringTooLong is an error set if a string decoded using the String method has a length that is too long.
-var errStringTooLong = errors.New("string length overflows a 32-bit integer")
-
 // StringUTF ...
 func (r *Reader) StringUTF(x *string) {
 	var length int16
 	r.Int16(&length)
-	l := int(length)
-	if l > math.MaxInt16 {
-		r.panic(errStringTooLong)
-	}
-	data := make([]byte, l)
-	if _, err := r.r.Read(data); err != nil {
-		r.panic(err)
+	if length < 0 {
+		r.panicf("negative string length %v", length)
 	}
+	data := r.readBytes(uint32(length))
 	*x = *(*string)(unsafe.Pointer(&data))
 }
 
@@ -82,30 +91,44 @@ func (r *Reader) StringUTF(x *string) {
 func (r *Reader) String(x *string) {
 	var length uint32
 	r.Varuint32(&length)
-	l := int(length)
-	if l > math.MaxInt32 {
-		r.panic(errStringTooLong)
-	}
+	data := r.readBytes(length)
+	*x = *(*string)(unsafe.Pointer(&data))
+}
+
+// readBytes reads l bytes from the underlying buffer. The Reader panics if l exceeds the maximum length or if
+// fewer than l bytes are left in the buffer. This is checked before any memory is allocated, so that a length
+// prefix in a malicious packet cannot cause large allocations.
+func (r *Reader) readBytes(l uint32) []byte {
+	r.checkLength(l)
 	data := make([]byte, l)
-	if _, err := r.r.Read(data); err != nil {
+	if _, err := io.ReadFull(r.r, data); err != nil {
 		r.panic(err)
 	}
-	*x = *(*string)(unsafe.Pointer(&data))
+	return data
+}
+
+// checkLength panics if the length l exceeds the maximum length of the Reader or the amount of bytes left in
+// the underlying buffer.
+func (r *Reader) checkLength(l uint32) {
+	if l > r.maxLength {
+		r.panicf("length %v exceeds the maximum length of %v", l, r.maxLength)
+	}
+	r.checkRemaining(uint64(l))
+}
+
+// checkRemaining panics if the underlying buffer holds fewer than n bytes. The check is only done if the
+// underlying buffer reports the amount of bytes left using a Len method, like bytes.Buffer does.
+func (r *Reader) checkRemaining(n uint64) {
+	if buf, ok := r.r.(interface{ Len() int }); ok && n > uint64(buf.Len()) {
+		r.panicf("length %v exceeds the %v bytes left in the buffer", n, buf.Len())
+	}
 }
 
 // ByteSlice reads a byte slice from the underlying buffer, similarly to String.
 func (r *Reader) ByteSlice(x *[]byte) {
 	var length uint32
 	r.Varuint32(&length)
-	l := int(length)
-	if l > math.MaxInt32 {
-		r.panic(errStringTooLong)
-	}
-	data := make([]byte, l)
-	if _, err := r.r.Read(data); err != nil {
-		r.panic(err)
-	}
-	*x = data
+	*x = r.readBytes(length)
 }
 
 // Vec3 reads three float32s into an mgl32.Vec3 from the underlying buffer.
@@ -398,6 +421,7 @@ func (r *Reader) ItemInstance(i *ItemInstance) {
 
 	buf := bytes.NewBuffer(extraData)
 	bufReader := NewReader(buf, r.shieldID, r.limitsEnabled)
+	bufReader.maxLength = r.maxLength
 
 	var length int16
 	bufReader.Int16(&length)
@@ -446,6 +470,7 @@ func (r *Reader) Item(x *ItemStack) {
 
 	buf := bytes.NewBuffer(extraData)
 	bufReader := NewReader(buf, r.shieldID, r.limitsEnabled)
+	bufReader.maxLength = r.maxLength
 
 	var length int16
 	bufReader.Int16(&length)
@@ -612,11 +637,15 @@ func (r *Reader) Bitset(x *Bitset, size int) {
 }
 
 // SliceLimit checks if the value passed is lower than the limit passed. If
-// not, the Reader panics.
+// not, the Reader panics. Regardless of the limit, the Reader also panics if
+// the value exceeds the maximum length of the Reader or the amount of bytes
+// left in the underlying buffer, as every element of a slice takes up at
+// least one byte.
 func (r *Reader) SliceLimit(value uint32, max uint32) {
 	if value > max && r.limitsEnabled {
 		r.panicf("slice length was too long: length of %v (max %v)", value, max)
 	}
+	r.checkLength(value)
 }
 
 // ShieldID returns the shield ID provided to the reader.
@@ -0,0 +1,105 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"math"
+	"runtime"
+	"testing"
+)
+
+// maxAllocBytes is the maximum amount of bytes that reading a huge length prefix may allocate in the tests.
+const maxAllocBytes = 1 << 20
+
+// TestReaderHugeLength checks that String, ByteSlice, StringUTF and Slice panic with an error when reading a
+// length prefix much larger than the data that follows it, without allocating memory for the declared length.
+func TestReaderHugeLength(t *testing.T) {
+	varuint32 := func(v uint32) []byte { return binary.AppendUvarint(nil, uint64(v)) }
+	int16LE := func(v int16) []byte { return binary.LittleEndian.AppendUint16(nil, uint16(v)) }
+
+	tests := map[string]struct {
+		prefix []byte
+		read   func(r *protocol.Reader)
+	}{
+		"String/MaxUint32":    {varuint32(math.MaxUint32), func(r *protocol.Reader) { var s string; r.String(&s) }},
+		"String/2GiB":         {varuint32(1 << 31), func(r *protocol.Reader) { var s string; r.String(&s) }},
+		"ByteSlice/MaxUint32": {varuint32(math.MaxUint32), func(r *protocol.Reader) { var b []byte; r.ByteSlice(&b) }},
+		"ByteSlice/2GiB":      {varuint32(1 << 31), func(r *protocol.Reader) { var b []byte; r.ByteSlice(&b) }},
+		// The length prefix of StringUTF is an int16, so the largest lengths it can hold are used instead.
+		"StringUTF/MaxInt16": {int16LE(math.MaxInt16), func(r *protocol.Reader) { var s string; r.StringUTF(&s) }},
+		"StringUTF/Negative": {int16LE(-1), func(r *protocol.Reader) { var s string; r.StringUTF(&s) }},
+		"Slice/MaxUint32": {varuint32(math.MaxUint32), func(r *protocol.Reader) {
+			var s []protocol.StackResourcePack
+			protocol.Slice(r, &s)
+		}},
+		"Slice/2GiB": {varuint32(1 << 31), func(r *protocol.Reader) {
+			var s []protocol.StackResourcePack
+			protocol.Slice(r, &s)
+		}},
+	}
+	for name, test := range tests {
+		for _, limits := range []bool{false, true} {
+			t.Run(name+map[bool]string{false: "", true: "/Limits"}[limits], func(t *testing.T) {
+				// A few bytes follow the prefix, so that the data is not simply empty.
+				data := append(test.prefix, 1, 2, 3, 4)
+				allocated := allocatedBytes(func() {
+					err := readErr(func() { test.read(protocol.NewReader(bytes.NewBuffer(data), 0, limits)) })
+					if err == nil {
+						t.Errorf("expected an error reading a huge length (limits=%v)", limits)
+					}
+				})
+				if allocated > maxAllocBytes {
+					t.Errorf("expected at most %v bytes to be allocated (limits=%v), got %v", maxAllocBytes, limits, allocated)
+				}
+			})
+		}
+	}
+}
+
+// TestReaderSetMaxLength checks that a Reader refuses strings longer than the maximum length set using
+// SetMaxLength, even if the data of the string is present, and accepts strings up to that length.
+func TestReaderSetMaxLength(t *testing.T) {
+	read := func(n int) error {
+		data := append(binary.AppendUvarint(nil, uint64(n)), bytes.Repeat([]byte{'a'}, n)...)
+		r := protocol.NewReader(bytes.NewBuffer(data), 0, false)
+		r.SetMaxLength(8)
+		return readErr(func() {
+			var s string
+			r.String(&s)
+		})
+	}
+	if err := read(8); err != nil {
+		t.Errorf("read string of maximum length: %v", err)
+	}
+	if err := read(9); err == nil {
+		t.Errorf("expected an error reading a string longer than the maximum length")
+	}
+}
+
+// readErr calls f and returns the error that the Reader panicked with, if any. If f panics with a value that
+// is not an error, or with a runtime error such as one from an out of range allocation, readErr panics again.
+func readErr(f func()) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			var ok bool
+			if _, isRuntime := v.(runtime.Error); isRuntime {
+				panic(v)
+			}
+			if err, ok = v.(error); !ok {
+				panic(v)
+			}
+		}
+	}()
+	f()
+	return nil
+}
+
+// allocatedBytes returns the amount of bytes allocated on the heap while calling f.
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
@@ -0,0 +1,107 @@
+package minecraft
+
+import (
+	"fmt"
+	"time"
+)
+
+// OverflowPolicy specifies what a Conn does with packets received once the amount of packets queued for
+// reading reaches its maximum, which happens when packets are read slower than they arrive.
+type OverflowPolicy uint8
+
+const (
+	// OverflowGrow keeps all packets received, regardless of the amount of packets already queued. The queue
+	// grows without bound if packets are not read. It is the default policy.
+	OverflowGrow OverflowPolicy = iota
+	// OverflowBlock stops reading from the connection until a packet is read from the queue. Packets are
+	// never dropped, but the other end of the connection may time out while reading is blocked.
+	OverflowBlock
+	// OverflowDropOldest drops the oldest packet in the queue to make room for the packet received. This is
+	// generally preferable for proxies that forward packets to a slow destination.
+	OverflowDropOldest
+	// OverflowDisconnect closes the connection with an error once the queue is full.
+	OverflowDisconnect
+)
+
+// defaultMaxQueuedPackets is the maximum amount of packets queued for reading if no maximum is set and a
+// policy other than OverflowGrow is used.
+const defaultMaxQueuedPackets = 1024
+
+// rateLimited checks if a packet received exceeds the maximum amount of packets that may be received in the
+// current second. It must be called once for every packet received.
+func (conn *Conn) rateLimited() bool {
+	if conn.maxPacketsPerSecond <= 0 {
+		return false
+	}
+	if now := time.Now().Unix(); now != conn.rateWindow {
+		conn.rateWindow, conn.rateCount = now, 0
+	}
+	conn.rateCount++
+	return conn.rateCount > int64(conn.maxPacketsPerSecond)
+}
+
+// queue queues a packet received after the connection was logged in, so that it may be read using ReadPacket
+// and similar methods. If the queue is full, the OverflowPolicy of the connection determines what happens.
+func (conn *Conn) queue(pkData *packetData) error {
+	if conn.rateLimited() {
+		conn.stats.packetsLimited.Add(1)
+		return nil
+	}
+	switch conn.overflowPolicy {
+	case OverflowBlock:
+		// The packets channel has a capacity equal to the maximum amount of queued packets when using this
+		// policy, so sending to it blocks until there is room.
+		select {
+		case <-conn.ctx.Done():
+		case conn.packets <- pkData:
+		}
+		return nil
+	case OverflowDropOldest:
+		if conn.queueLen() >= conn.maxQueuedPackets {
+			if _, ok := conn.takeDeferredPacket(); !ok {
+				select {
+				case <-conn.packets:
+				default:
+				}
+			}
+			conn.stats.packetsDropped.Add(1)
+		}
+	case OverflowDisconnect:
+		if conn.queueLen() >= conn.maxQueuedPackets {
+			conn.stats.packetsDropped.Add(1)
+			return fmt.Errorf("packet queue full: %v packets were not read", conn.queueLen())
+		}
+	}
+	select {
+	case <-conn.ctx.Done():
+	case previous := <-conn.packets:
+		// There was already a packet in this channel, so take it out and defer it so that it is read
+		// next.
+		conn.deferPacket(previous)
+	default:
+	}
+	select {
+	case <-conn.ctx.Done():
+	case conn.packets <- pkData:
+	}
+	return nil
+}
+
+// queueLen returns the amount of packets currently queued for reading.
+func (conn *Conn) queueLen() int {
+	conn.deferredPacketMu.Lock()
+	defer conn.deferredPacketMu.Unlock()
+	return len(conn.deferredPackets) + len(conn.packets)
+}
+
+// setOverflowPolicy sets the OverflowPolicy of the connection and the maximum amount of packets that may be
+// queued for reading. It must be called before the connection starts receiving packets.
+func (conn *Conn) setOverflowPolicy(policy OverflowPolicy, maxQueued int) {
+	if maxQueued <= 0 {
+		maxQueued = defaultMaxQueuedPackets
+	}
+	conn.overflowPolicy, conn.maxQueuedPackets = policy, maxQueued
+	if policy == OverflowBlock {
+		conn.packets = make(chan *packetData, maxQueued)
+	}
+}
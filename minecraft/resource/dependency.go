@@ -0,0 +1,71 @@
+package resource
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortByDependencies returns a copy of the packs passed ordered so that every pack comes after the packs it
+// depends on, as found using Pack.Dependencies. Packs are otherwise kept in the order passed. Dependencies
+// are matched by UUID only, regardless of case, and dependencies on packs that are not passed, such as those
+// on script modules, are ignored. An error is returned if the packs depend on each other in a cycle.
+func SortByDependencies(packs []*Pack) ([]*Pack, error) {
+	byUUID := make(map[string]*Pack, len(packs))
+	for _, pack := range packs {
+		byUUID[strings.ToLower(pack.UUID().String())] = pack
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*Pack]int, len(packs))
+	sorted := make([]*Pack, 0, len(packs))
+	// path holds the packs currently being visited, so that the cycle may be included in the error.
+	var path []*Pack
+
+	var visit func(pack *Pack) error
+	visit = func(pack *Pack) error {
+		switch state[pack] {
+		case visited:
+			return nil
+		case visiting:
+			return cycleError(path, pack)
+		}
+		state[pack] = visiting
+		path = append(path, pack)
+		for _, dep := range pack.Dependencies() {
+			if depPack, ok := byUUID[strings.ToLower(dep.UUID)]; ok {
+				if err := visit(depPack); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[pack] = visited
+		sorted = append(sorted, pack)
+		return nil
+	}
+	for _, pack := range packs {
+		if err := visit(pack); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// cycleError returns an error describing the dependency cycle that ends at the pack passed, which is part of
+// path.
+func cycleError(path []*Pack, pack *Pack) error {
+	var names []string
+	for i, p := range path {
+		if p == pack {
+			for _, p := range path[i:] {
+				names = append(names, fmt.Sprintf("%v (%v)", p.Name(), p.UUID()))
+			}
+			break
+		}
+	}
+	names = append(names, fmt.Sprintf("%v (%v)", pack.Name(), pack.UUID()))
+	return fmt.Errorf("sort resource packs: dependency cycle: %v", strings.Join(names, " -> "))
+}
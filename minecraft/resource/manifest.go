@@ -1,6 +1,11 @@
 package resource
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
 
 // Documentation on this may be found here:
 // https://learn.microsoft.com/en-us/minecraft/creator/reference/content/addonsreference/examples/addonmanifest
@@ -20,6 +25,9 @@ type Manifest struct {
 	// Capabilities are the different features that the pack makes use of that aren't necessarily enabled by
 	// default. For a list of options, see below.
 	Capabilities []Capability `json:"capabilities,omitempty"`
+	// Metadata contains optional information about the pack, such as its authors. It is nil if the manifest
+	// has no metadata section.
+	Metadata *Metadata `json:"metadata,omitempty"`
 
 	// worldTemplate holds a value indicating if the pack holds an entire world template or not.
 	worldTemplate bool
@@ -48,14 +56,30 @@ type Module struct {
 	UUID string `json:"uuid"`
 	// Description is a short description of the module. This is not user-facing at the moment.
 	Description string `json:"description"`
-	// Type is the type of the module. Can be any of the following: resources, data, client_data, interface or
-	// world_template.
+	// Type is the type of the module. Can be any of the ModuleType constants, such as ModuleTypeResources.
 	Type string `json:"type"`
 	// Version is the version of the module in the same format as the pack's version in the header. This can
 	// be used to further identify changes in the pack.
 	Version [3]int `json:"version"`
 }
 
+const (
+	// ModuleTypeResources is the type of modules holding resources, such as textures and models.
+	ModuleTypeResources = "resources"
+	// ModuleTypeData is the type of modules holding behaviours, such as entity definitions and loot tables.
+	ModuleTypeData = "data"
+	// ModuleTypeClientData is the type of modules holding client scripts.
+	ModuleTypeClientData = "client_data"
+	// ModuleTypeScript is the type of modules holding scripts run by the server using the scripting API.
+	ModuleTypeScript = "script"
+	// ModuleTypeInterface is the type of modules holding custom UI.
+	ModuleTypeInterface = "interface"
+	// ModuleTypeWorldTemplate is the type of modules holding a world template.
+	ModuleTypeWorldTemplate = "world_template"
+	// ModuleTypeSkinPack is the type of modules holding skins.
+	ModuleTypeSkinPack = "skin_pack"
+)
+
 // Dependency describes a pack that this pack depends on in order to work.
 type Dependency struct {
 	// UUID is the unique identifier of the pack that this pack depends on. It needs to be the exact same UUID
@@ -82,3 +106,56 @@ type Metadata struct {
 	// URL is the home website of the creator of the pack.
 	URL string `json:"url,omitempty"`
 }
+
+// UnmarshalJSON decodes the metadata of a manifest. Manifests store the authors of a pack either as a single
+// string or as an array of names, which are joined by commas into Author. Metadata is optional and is not
+// used by Minecraft to load a pack, so fields that could not be decoded are left empty rather than an error
+// being returned.
+func (m *Metadata) UnmarshalJSON(b []byte) error {
+	type metadata Metadata
+	var data struct {
+		metadata
+		Authors json.RawMessage `json:"authors"`
+	}
+	_ = json.Unmarshal(b, &data)
+	*m = Metadata(data.metadata)
+
+	var authors []string
+	if err := json.Unmarshal(data.Authors, &m.Author); err != nil && json.Unmarshal(data.Authors, &authors) == nil {
+		m.Author = strings.Join(authors, ", ")
+	}
+	return nil
+}
+
+// HasModule checks if any of the modules of the manifest has one of the types passed, such as
+// ModuleTypeResources.
+func (m Manifest) HasModule(types ...string) bool {
+	for _, module := range m.Modules {
+		for _, t := range types {
+			if module.Type == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validate checks if the manifest holds the fields that Minecraft needs to identify the pack and returns an
+// error if it does not.
+func (m Manifest) validate() error {
+	if m.Header.UUID == uuid.Nil {
+		return fmt.Errorf("header: missing uuid")
+	}
+	if m.Header.Version[0] < 0 || m.Header.Version[1] < 0 || m.Header.Version[2] < 0 {
+		return fmt.Errorf("header: invalid version %v", m.Header.Version)
+	}
+	if len(m.Modules) == 0 {
+		return fmt.Errorf("no modules")
+	}
+	for i, module := range m.Modules {
+		if module.Type == "" {
+			return fmt.Errorf("module %v: missing type", i)
+		}
+	}
+	return nil
+}
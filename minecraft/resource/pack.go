@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/muhammadmuzzammil1998/jsonc"
@@ -13,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Pack is a container of a resource pack parsed from a directory or a .zip archive (or .mcpack). It holds
@@ -25,9 +27,12 @@ type Pack struct {
 	// downloadURL is the URL that the resource pack can be downloaded from. If the string is empty, then the
 	// resource pack will be downloaded over RakNet rather than HTTP.
 	downloadURL string
-	// content is a bytes.Reader that contains the full content of the zip file. It is used to send the full
-	// data to a client.
-	content *bytes.Reader
+	// content is an io.ReaderAt that holds the full content of the zip file. It is used to send the full
+	// data to a client. For packs obtained using StreamPath, content is a fileContent, so that the archive
+	// does not have to be held in memory.
+	content io.ReaderAt
+	// size is the total size in bytes of the content of the zip file.
+	size int64
 	// contentKey is the key used to encrypt the files. The client uses this to decrypt the resource pack if encrypted.
 	// If nothing is encrypted, this field can be left as an empty string.
 	contentKey string
@@ -35,15 +40,48 @@ type Pack struct {
 	// checksum is the SHA256 checksum of the full content of the file. It is sent to the client so that it
 	// can 'verify' the download.
 	checksum [32]byte
+
+	// stack is the list of the resource pack stack that the pack is placed in, as set using WithStack.
+	stack Stack
 }
 
+// Stack is a list of the resource pack stack sent to clients that a Pack may be placed in. The stack holds a
+// list of behaviour packs and a list of texture packs.
+type Stack uint8
+
+const (
+	// StackAuto places a Pack in the behaviour pack list if HasBehaviours returns true, and in the texture
+	// pack list otherwise. It is the Stack of every Pack unless changed using WithStack.
+	StackAuto Stack = iota
+	// StackBehaviour places a Pack in the behaviour pack list.
+	StackBehaviour
+	// StackTexture places a Pack in the texture pack list.
+	StackTexture
+)
+
 // ReadPath compiles a resource pack found at the path passed. The resource pack must either be a zip archive
 // (extension does not matter, could be .zip or .mcpack), or a directory containing a resource pack. In the
 // case of a directory, the directory is compiled into an archive and the pack is parsed from that.
 // ReadPath operates assuming the resource pack has a 'manifest.json' file in it. If it does not, the function
-// will fail and return an error.
+// will fail and return an error. The archive is validated before ReadPath returns: an error is also returned
+// if it is not a valid zip archive, if any of its files is corrupted or if its manifest is invalid, so that
+// broken packs are found when a server starts rather than when a client downloads them.
+// The full content of the archive is loaded into memory. Use StreamPath to read large archives from disk
+// instead.
 func ReadPath(path string) (*Pack, error) {
-	return compile(path)
+	return compile(path, false)
+}
+
+// StreamPath compiles a resource pack found at the path passed, like ReadPath. Unlike ReadPath, if the path
+// points to a zip archive, the content of the archive is read from disk every time it is needed rather than
+// being loaded into memory, which is useful for large packs. Directories are still compiled into an archive
+// that is loaded into memory.
+// The Pack does not keep the file open, so it needs no closing. Reading the content of the Pack fails with
+// ErrPackModified if the size or modification time of the file changed after StreamPath returned, so that
+// clients are never sent content that does not match the checksum of the Pack. The file should therefore not
+// be modified, moved or removed while the Pack, or a copy of it, is in use.
+func StreamPath(path string) (*Pack, error) {
+	return compile(path, true)
 }
 
 // ReadURL downloads a resource pack found at the URL passed and compiles it. The resource pack must be a valid
@@ -73,7 +111,7 @@ func ReadURL(url string) (*Pack, error) {
 // will fail and return an error.
 // Unlike ReadPath, MustReadPath does not return an error and panics if an error occurs instead.
 func MustReadPath(path string) *Pack {
-	pack, err := compile(path)
+	pack, err := compile(path, false)
 	if err != nil {
 		panic(err)
 	}
@@ -100,11 +138,15 @@ func Read(r io.Reader) (*Pack, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create temp zip archive: %w", err)
 	}
-	_, _ = io.Copy(temp, r)
+	if _, err := io.Copy(temp, r); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+		return nil, fmt.Errorf("write temp zip archive: %w", err)
+	}
 	if err := temp.Close(); err != nil {
 		return nil, fmt.Errorf("close temp zip archive: %w", err)
 	}
-	pack, parseErr := ReadPath(temp.Name())
+	pack, parseErr := compile(temp.Name(), false)
 	if err := os.Remove(temp.Name()); err != nil {
 		return nil, fmt.Errorf("remove temp zip archive: %w", err)
 	}
@@ -144,40 +186,22 @@ func (pack *Pack) Dependencies() []Dependency {
 	return pack.manifest.Dependencies
 }
 
-// HasScripts checks if any of the modules of the resource pack have the type 'client_data', meaning they have
-// scripts in them.
+// HasScripts checks if any of the modules of the resource pack have the type ModuleTypeClientData, meaning
+// they have scripts in them.
 func (pack *Pack) HasScripts() bool {
-	for _, module := range pack.manifest.Modules {
-		if module.Type == "client_data" {
-			// The module has the client_data type, meaning it holds client scripts.
-			return true
-		}
-	}
-	return false
+	return pack.manifest.HasModule(ModuleTypeClientData)
 }
 
-// HasBehaviours checks if any of the modules of the resource pack have either the type 'data' or
-// 'client_data', meaning they contain behaviours (or scripts).
+// HasBehaviours checks if any of the modules of the resource pack have either the type ModuleTypeData or
+// ModuleTypeClientData, meaning they contain behaviours (or scripts).
 func (pack *Pack) HasBehaviours() bool {
-	for _, module := range pack.manifest.Modules {
-		if module.Type == "client_data" || module.Type == "data" {
-			// The module has the client_data or data type, meaning it holds behaviours.
-			return true
-		}
-	}
-	return false
+	return pack.manifest.HasModule(ModuleTypeData, ModuleTypeClientData)
 }
 
-// HasTextures checks if any of the modules of the resource pack have the type 'resources', meaning they have
-// textures in them.
+// HasTextures checks if any of the modules of the resource pack have the type ModuleTypeResources, meaning
+// they have textures in them.
 func (pack *Pack) HasTextures() bool {
-	for _, module := range pack.manifest.Modules {
-		if module.Type == "resources" {
-			// The module has the resources type, meaning it holds textures.
-			return true
-		}
-	}
-	return false
+	return pack.manifest.HasModule(ModuleTypeResources)
 }
 
 // HasWorldTemplate checks if the resource compiled holds a level.dat in it, indicating that the resource is
@@ -200,7 +224,7 @@ func (pack *Pack) Checksum() [32]byte {
 
 // Len returns the total length in bytes of the content of the archive that contained the resource pack.
 func (pack *Pack) Len() int {
-	return pack.content.Len()
+	return int(pack.size)
 }
 
 // DataChunkCount returns the amount of chunks the data of the resource pack is split into if each chunk has
@@ -237,8 +261,39 @@ func (pack Pack) WithContentKey(key string) *Pack {
 	return &pack
 }
 
+// WithDownloadURL creates a copy of the pack and sets the URL that the pack can be downloaded from to the URL
+// provided, after which the new Pack is returned. Servers advertise this URL to clients, which then download
+// the pack over HTTP rather than requesting its chunks over the connection, for example from a CDN. The URL
+// must serve the same archive as the pack. The content of a pack with a download URL is never sent over the
+// connection, even if a client requests it.
+func (pack Pack) WithDownloadURL(url string) *Pack {
+	pack.downloadURL = url
+	return &pack
+}
+
+// WithStack creates a copy of the pack and sets the list of the resource pack stack that it is placed in to
+// the Stack passed, after which the new Pack is returned. By default, the list is chosen based on whether the
+// pack has behaviours. Within each list, packs keep the order in which they were added to the server.
+func (pack Pack) WithStack(stack Stack) *Pack {
+	pack.stack = stack
+	return &pack
+}
+
+// Stack returns the list of the resource pack stack that the pack is placed in. It returns either
+// StackBehaviour or StackTexture: If the pack has StackAuto set, the list is chosen using HasBehaviours.
+func (pack *Pack) Stack() Stack {
+	if pack.stack != StackAuto {
+		return pack.stack
+	}
+	if pack.HasBehaviours() {
+		return StackBehaviour
+	}
+	return StackTexture
+}
+
 // Manifest returns the manifest found in the manifest.json of the resource pack. It contains information
-// about the pack such as its name.
+// about the pack such as its name, description, minimum engine version, modules and dependencies. The manifest
+// is validated when the pack is read, so its header always holds a UUID and it holds at least one module.
 func (pack *Pack) Manifest() Manifest {
 	return *pack.manifest
 }
@@ -249,8 +304,9 @@ func (pack *Pack) String() string {
 }
 
 // compile compiles the resource pack found in path, either a zip archive or a directory, and returns a
-// resource pack if successful.
-func compile(path string) (*Pack, error) {
+// resource pack if successful. If stream is true and path is a zip archive, the content of the archive is read
+// from the opened file rather than being loaded into memory.
+func compile(path string, stream bool) (*Pack, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, fmt.Errorf("open resource pack path: %w", err)
@@ -260,8 +316,10 @@ func compile(path string) (*Pack, error) {
 		if err != nil {
 			return nil, err
 		}
-		// We set the path to the temp zip archive we just made.
+		// We set the path to the temp zip archive we just made. The archive is removed below, so its content
+		// cannot be streamed from disk.
 		path = temp.Name()
+		stream = false
 
 		// Make sure we close the temp file and remove it at the end. We don't need to keep it, as we read all
 		// the content in a byte slice.
@@ -276,6 +334,35 @@ func compile(path string) (*Pack, error) {
 		return nil, fmt.Errorf("read manifest: %w", err)
 	}
 
+	if stream {
+		// We compute the SHA256 checksum by reading through the archive once and read its content from disk
+		// again when needed, so that its content never has to be held in memory. The absolute path is stored
+		// so that the file is still found if the working directory changes.
+		if path, err = filepath.Abs(path); err != nil {
+			return nil, fmt.Errorf("resolve resource pack path: %w", err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open resource pack file: %w", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("stat resource pack file: %w", err)
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, f)
+		if err != nil {
+			return nil, fmt.Errorf("read resource pack file content: %w", err)
+		}
+		if n != info.Size() {
+			return nil, fmt.Errorf("read resource pack file content: %w", ErrPackModified)
+		}
+		pack := &Pack{manifest: manifest, content: fileContent{path: path, size: n, modTime: info.ModTime()}, size: n}
+		copy(pack.checksum[:], h.Sum(nil))
+		return pack, nil
+	}
+
 	// Then we read the entire content of the zip archive into a byte slice and compute the SHA256 checksum
 	// and a reader.
 	content, err := os.ReadFile(path)
@@ -285,7 +372,38 @@ func compile(path string) (*Pack, error) {
 	checksum := sha256.Sum256(content)
 	contentReader := bytes.NewReader(content)
 
-	return &Pack{manifest: manifest, checksum: checksum, content: contentReader}, nil
+	return &Pack{manifest: manifest, checksum: checksum, content: contentReader, size: int64(len(content))}, nil
+}
+
+// ErrPackModified is returned when reading the content of a Pack obtained using StreamPath if its file was
+// modified after the Pack was compiled.
+var ErrPackModified = errors.New("resource pack file was modified after it was read")
+
+// fileContent is an io.ReaderAt that reads the content of the file at its path. The file is opened for every
+// read, so that Packs read from disk, and their copies, never hold an open file descriptor. The size and
+// modification time of the file when the Pack was compiled are used to detect changes to the file.
+type fileContent struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// ReadAt opens the file, reads len(b) bytes from it starting at offset off and closes it again. If the size
+// or modification time of the file changed, ErrPackModified is returned.
+func (c fileContent) ReadAt(b []byte, off int64) (n int, err error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() != c.size || !info.ModTime().Equal(c.modTime) {
+		return 0, ErrPackModified
+	}
+	return f.ReadAt(b, off)
 }
 
 // createTempArchive creates a zip archive from the files in the path passed and writes it to a temporary
@@ -360,7 +478,7 @@ func createTempFile() (*os.File, error) {
 	return temp, nil
 }
 
-// packReader wraps around a zip.Reader to provide file finding functionality.
+// packReader wraps around a zip.Reader to provide file finding and verification functionality.
 type packReader struct {
 	*zip.ReadCloser
 }
@@ -382,6 +500,28 @@ func (reader packReader) find(fileName string) (io.ReadCloser, error) {
 	return nil, fmt.Errorf("'%v' not found in zip", fileName)
 }
 
+// verify reads all files in the zip archive to check that none of them are truncated or corrupted, so that
+// a broken archive is rejected when the pack is read rather than when a client fails to load it.
+func (reader packReader) verify() error {
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		fileReader, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("open zip file %v: %w", file.Name, err)
+		}
+		// Reading a file in its entirety makes the zip reader check it against the CRC-32 checksum of the
+		// archive.
+		_, err = io.Copy(io.Discard, fileReader)
+		_ = fileReader.Close()
+		if err != nil {
+			return fmt.Errorf("read zip file %v: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
 // readManifest reads the manifest from the resource pack located at the path passed. If not found in the root
 // of the resource pack, it will also attempt to find it deeper down into the archive.
 func readManifest(path string) (*Manifest, error) {
@@ -393,6 +533,9 @@ func readManifest(path string) (*Manifest, error) {
 	defer func() {
 		_ = r.Close()
 	}()
+	if err := reader.verify(); err != nil {
+		return nil, fmt.Errorf("verify zip archive: %w", err)
+	}
 
 	// Try to find the manifest file in the zip.
 	manifestFile, err := reader.find("manifest.json")
@@ -412,6 +555,9 @@ func readManifest(path string) (*Manifest, error) {
 	if err := jsonc.Unmarshal(allData, manifest); err != nil {
 		return nil, fmt.Errorf("decode manifest JSON: %w (data: %v)", err, string(allData))
 	}
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
 
 	if _, err := reader.find("level.dat"); err == nil {
 		manifest.worldTemplate = true
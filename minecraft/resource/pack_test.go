@@ -0,0 +1,226 @@
+package resource_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReadInvalid checks that ReadPath and Read both return an error for archives that are not valid zip
+// archives, that hold a corrupted file or that have a missing or invalid manifest.
+func TestReadInvalid(t *testing.T) {
+	valid := testManifest()
+	tests := map[string][]byte{
+		"Truncated": func() []byte {
+			data := testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, valid)})
+			return data[:len(data)/2]
+		}(),
+		"Checksum": func() []byte {
+			content := bytes.Repeat([]byte{0x42}, 64)
+			data := testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, valid), "textures/data.bin": content})
+			// The file is stored without compression, so its content may be changed directly, after which its
+			// CRC-32 checksum no longer matches.
+			data[bytes.Index(data, content)] ^= 0xff
+			return data
+		}(),
+		"MissingManifest": testArchive(t, map[string][]byte{"textures/data.bin": {1, 2, 3}}),
+		"NilUUID": func() []byte {
+			m := testManifest()
+			m.Header.UUID = uuid.Nil
+			return testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, m)})
+		}(),
+		"NoModules": func() []byte {
+			m := testManifest()
+			m.Modules = nil
+			return testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, m)})
+		}(),
+		"ModuleWithoutType": func() []byte {
+			m := testManifest()
+			m.Modules[0].Type = ""
+			return testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, m)})
+		}(),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := resource.ReadPath(writeArchive(t, data)); err == nil {
+				t.Errorf("ReadPath: expected an error")
+			}
+			if _, err := resource.Read(bytes.NewReader(data)); err == nil {
+				t.Errorf("Read: expected an error")
+			}
+		})
+	}
+}
+
+// TestReadValid checks that ReadPath and Read both load a valid pack with the UUID and version of its
+// manifest.
+func TestReadValid(t *testing.T) {
+	m := testManifest()
+	data := testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, m), "textures/data.bin": {1, 2, 3}})
+
+	fromPath, err := resource.ReadPath(writeArchive(t, data))
+	if err != nil {
+		t.Fatalf("ReadPath: %v", err)
+	}
+	fromReader, err := resource.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	for _, pack := range []*resource.Pack{fromPath, fromReader} {
+		if pack.UUID() != m.Header.UUID || pack.Version() != "1.0.0" {
+			t.Errorf("pack: expected %v v1.0.0, got %v v%v", m.Header.UUID, pack.UUID(), pack.Version())
+		}
+		if !pack.HasTextures() {
+			t.Errorf("pack: expected textures")
+		}
+	}
+}
+
+// TestReadMetadataAuthors checks that packs load with the authors in the metadata of their manifest stored
+// either as a string or as an array, and that authors of an unexpected type do not stop a pack from loading.
+func TestReadMetadataAuthors(t *testing.T) {
+	tests := map[string]struct {
+		authors  string
+		expected string
+	}{
+		"String":  {`"Name"`, "Name"},
+		"Array":   {`["Name", "Other"]`, "Name, Other"},
+		"Invalid": {`5`, ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := testManifest()
+			manifest := fmt.Sprintf(`{"format_version": 2, "header": {"name": "Test", "uuid": %q, "version": [1, 0, 0]},
+				"modules": [{"uuid": %q, "type": "resources", "version": [1, 0, 0]}],
+				"metadata": {"authors": %v, "license": "MIT"}}`, m.Header.UUID, m.Modules[0].UUID, test.authors)
+			data := testArchive(t, map[string][]byte{"manifest.json": []byte(manifest)})
+
+			pack, err := resource.Read(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			metadata := pack.Manifest().Metadata
+			if metadata == nil {
+				t.Fatalf("manifest metadata: expected metadata, got nil")
+			}
+			if metadata.Author != test.expected || metadata.License != "MIT" {
+				t.Errorf("manifest metadata: expected author %q and license MIT, got %+v", test.expected, *metadata)
+			}
+		})
+	}
+}
+
+// TestStreamPath checks that a pack obtained using StreamPath reads its content from disk, also after the
+// working directory changes, and that reading fails once the file was changed rather than returning content
+// that does not match the checksum of the pack. A pack obtained using ReadPath keeps its original content.
+func TestStreamPath(t *testing.T) {
+	data := testArchive(t, map[string][]byte{"manifest.json": encodeManifest(t, testManifest()), "textures/data.bin": {1, 2, 3}})
+	path := writeArchive(t, data)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory: %v", err)
+	}
+	if err := os.Chdir(filepath.Dir(path)); err != nil {
+		t.Fatalf("change working directory: %v", err)
+	}
+	pack, err := resource.StreamPath(filepath.Base(path))
+	if err != nil {
+		t.Fatalf("StreamPath: %v", err)
+	}
+	if err := os.Chdir(wd); err != nil {
+		t.Fatalf("change working directory back: %v", err)
+	}
+	if pack.Checksum() != sha256.Sum256(data) || pack.Len() != len(data) {
+		t.Fatalf("pack: checksum or length does not match the archive")
+	}
+
+	chunk := make([]byte, 16)
+	if _, err := pack.ReadAt(chunk, 4); err != nil {
+		t.Fatalf("read chunk: %v", err)
+	}
+	if !bytes.Equal(chunk, data[4:20]) {
+		t.Fatalf("read chunk: expected %x, got %x", data[4:20], chunk)
+	}
+
+	loaded, err := resource.ReadPath(path)
+	if err != nil {
+		t.Fatalf("ReadPath: %v", err)
+	}
+
+	// The archive is changed without changing its size, so that the change is only found through the
+	// modification time of the file.
+	changed := bytes.Clone(data)
+	changed[4] ^= 0xff
+	if err := os.WriteFile(path, changed, 0644); err != nil {
+		t.Fatalf("change archive: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("change modification time: %v", err)
+	}
+	if _, err := pack.ReadAt(chunk, 4); !errors.Is(err, resource.ErrPackModified) {
+		t.Fatalf("read chunk after change: expected %v, got %v", resource.ErrPackModified, err)
+	}
+	// A pack obtained using ReadPath holds the archive in memory, so it is not affected by the change.
+	if _, err := loaded.ReadAt(chunk, 4); err != nil || !bytes.Equal(chunk, data[4:20]) {
+		t.Fatalf("read chunk of loaded pack after change: expected %x, got %x (err=%v)", data[4:20], chunk, err)
+	}
+}
+
+// testManifest returns a valid manifest of a pack with a single resources module.
+func testManifest() resource.Manifest {
+	return resource.Manifest{
+		FormatVersion: 2,
+		Header:        resource.Header{Name: "Test", UUID: uuid.New(), Version: [3]int{1, 0, 0}},
+		Modules:       []resource.Module{{UUID: uuid.NewString(), Type: resource.ModuleTypeResources, Version: [3]int{1, 0, 0}}},
+	}
+}
+
+// encodeManifest encodes the manifest passed to JSON.
+func encodeManifest(t *testing.T, m resource.Manifest) []byte {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("encode manifest: %v", err)
+	}
+	return data
+}
+
+// testArchive creates a zip archive holding the files passed, indexed by their name. The files are stored
+// without compression.
+func testArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatalf("create %v: %v", name, err)
+		}
+		_, _ = f.Write(content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writeArchive writes the data passed to a file in a temporary directory and returns its path.
+func writeArchive(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pack.mcpack")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	return path
+}
@@ -12,14 +12,35 @@ import (
 type resourcePackQueue struct {
 	packs           []*resource.Pack
 	packsToDownload map[string]*resource.Pack
-	currentPack     *resource.Pack
-	currentOffset   uint64
+	// packsFromURL holds the packs requested that have a download URL. These are not sent over the
+	// connection, as the client is expected to download them from their URL.
+	packsFromURL  map[string]*resource.Pack
+	currentPack   *resource.Pack
+	currentOffset uint64
+	chunkSize     uint64
 
 	packAmount       int
 	downloadingPacks map[string]downloadingPack
 	awaitingPacks    map[string]*downloadingPack
 }
 
+const (
+	// defaultPackChunkSize is the size of a single chunk of data from a resource pack if no chunk size is
+	// set: 128 kB.
+	defaultPackChunkSize = 1024 * 128
+	// minPackChunkSize and maxPackChunkSize are the bounds that a resource pack chunk size set is clamped to.
+	minPackChunkSize, maxPackChunkSize = 1024 * 4, 1024 * 1024 * 2
+)
+
+// packChunkSize returns the resource pack chunk size to use for a chunk size n configured. If n is 0, the
+// default chunk size is returned. Otherwise, n is clamped to sane bounds.
+func packChunkSize(n int) uint64 {
+	if n == 0 {
+		return defaultPackChunkSize
+	}
+	return uint64(min(max(n, minPackChunkSize), maxPackChunkSize))
+}
+
 // downloadingPack is a resource pack that is being downloaded by a client connection.
 type downloadingPack struct {
 	buf           *bytes.Buffer
@@ -31,9 +52,11 @@ type downloadingPack struct {
 }
 
 // Request 'requests' all resource packs passed, provided they all exist in the resourcePackQueue. If not,
-// an error is returned.
+// an error is returned. Packs with a download URL are not queued for download, but are added to
+// packsFromURL instead.
 func (queue *resourcePackQueue) Request(packs []string) error {
 	queue.packsToDownload = make(map[string]*resource.Pack)
+	queue.packsFromURL = make(map[string]*resource.Pack)
 	for _, packUUID := range packs {
 		found := false
 		for _, pack := range queue.packs {
@@ -41,7 +64,11 @@ func (queue *resourcePackQueue) Request(packs []string) error {
 			// too in order to find the proper pack.
 			id := pack.UUID().String()
 			if id+"_"+pack.Version() == packUUID {
-				queue.packsToDownload[id] = pack
+				if pack.DownloadURL() != "" {
+					queue.packsFromURL[id] = pack
+				} else {
+					queue.packsToDownload[id] = pack
+				}
 				found = true
 				break
 			}
@@ -78,8 +105,8 @@ func (queue *resourcePackQueue) NextPack() (pk *packet.ResourcePackDataInfo, ok
 		}
 		return &packet.ResourcePackDataInfo{
 			UUID:          pack.UUID().String(),
-			DataChunkSize: packChunkSize,
-			ChunkCount:    uint32(pack.DataChunkCount(packChunkSize)),
+			DataChunkSize: uint32(queue.chunkSize),
+			ChunkCount:    uint32(pack.DataChunkCount(int(queue.chunkSize))),
 			Size:          uint64(pack.Len()),
 			Hash:          checksum[:],
 			PackType:      packType,
@@ -0,0 +1,53 @@
+package minecraft
+
+// ResourcePackResult is the outcome of offering a resource pack to a client connected through a Listener. It
+// is derived from the ResourcePackClientResponse packets sent by the client during login.
+type ResourcePackResult uint8
+
+const (
+	// ResourcePackPending means the pack was offered to the client, but the client has not yet responded to
+	// it.
+	ResourcePackPending ResourcePackResult = iota
+	// ResourcePackCached means the client requested other packs to download, but not this one, which means it
+	// already had the pack stored locally.
+	ResourcePackCached
+	// ResourcePackDownloading means the client requested the pack and its chunks are currently being sent.
+	ResourcePackDownloading
+	// ResourcePackDownloaded means the client requested the pack and all of its chunks were sent.
+	ResourcePackDownloaded
+	// ResourcePackRefused means the client refused the resource packs offered by the server.
+	ResourcePackRefused
+	// ResourcePackFailed means the client requested the pack, but finished the resource pack sequence
+	// before all of its chunks were sent.
+	ResourcePackFailed
+	// ResourcePackNotRequested means the client finished the resource pack sequence without requesting any
+	// of the packs offered. The client either already had all packs stored locally or declined to download
+	// them: The protocol does not tell these cases apart.
+	ResourcePackNotRequested
+	// ResourcePackDownloadURL means the pack has a download URL, so it was not sent over the connection. The
+	// client is expected to download the pack from the URL, but whether it did is not known to the server.
+	ResourcePackDownloadURL
+)
+
+// String returns a readable name of the ResourcePackResult.
+func (r ResourcePackResult) String() string {
+	switch r {
+	case ResourcePackPending:
+		return "pending"
+	case ResourcePackCached:
+		return "cached"
+	case ResourcePackDownloading:
+		return "downloading"
+	case ResourcePackDownloaded:
+		return "downloaded"
+	case ResourcePackRefused:
+		return "refused"
+	case ResourcePackFailed:
+		return "failed"
+	case ResourcePackNotRequested:
+		return "not requested"
+	case ResourcePackDownloadURL:
+		return "download URL"
+	}
+	return "unknown"
+}
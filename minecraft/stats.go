@@ -0,0 +1,59 @@
+package minecraft
+
+import "sync/atomic"
+
+// ConnStats holds statistics on the packets sent and received over a Conn. Byte counts are those of the
+// packets themselves, before compression and encryption are applied.
+type ConnStats struct {
+	// PacketsRead and PacketsWritten are the amount of packets received and sent over the Conn.
+	PacketsRead, PacketsWritten uint64
+	// BytesRead and BytesWritten are the total size in bytes of all packets received and sent over the Conn.
+	BytesRead, BytesWritten uint64
+	// BatchesRead and BatchesWritten are the amount of packet batches received and sent over the Conn. Each
+	// batch holds one or more packets.
+	BatchesRead, BatchesWritten uint64
+	// PacketsQueued is the amount of packets currently received and waiting to be read.
+	PacketsQueued uint64
+	// PacketsDropped is the amount of packets dropped because the queue of packets waiting to be read was
+	// full. Packets are only dropped if an OverflowPolicy other than OverflowGrow is used.
+	PacketsDropped uint64
+	// PacketsLimited is the amount of packets dropped because more packets were received within a second than
+	// allowed by ListenConfig.MaxPacketsPerSecond.
+	PacketsLimited uint64
+	// BytesBuffered is the total size in bytes of the packets currently written to the Conn, but not yet
+	// flushed.
+	BytesBuffered uint64
+	// WireBytesWritten is the total size in bytes of all batches sent over the Conn, after compression and
+	// encryption were applied. It is the amount of bytes actually written to the underlying net.Conn.
+	WireBytesWritten uint64
+}
+
+// connStats holds the counters of a Conn that are exposed through a ConnStats. All counters may be updated
+// from multiple goroutines simultaneously.
+type connStats struct {
+	packetsRead, packetsWritten atomic.Uint64
+	bytesRead, bytesWritten     atomic.Uint64
+	batchesRead, batchesWritten atomic.Uint64
+	packetsDropped              atomic.Uint64
+	packetsLimited              atomic.Uint64
+	bytesBuffered               atomic.Uint64
+	wireBytesWritten            atomic.Uint64
+}
+
+// Stats returns the current statistics of the packets sent and received over the Conn. Stats is safe to call
+// from multiple goroutines simultaneously.
+func (conn *Conn) Stats() ConnStats {
+	return ConnStats{
+		PacketsRead:      conn.stats.packetsRead.Load(),
+		PacketsWritten:   conn.stats.packetsWritten.Load(),
+		BytesRead:        conn.stats.bytesRead.Load(),
+		BytesWritten:     conn.stats.bytesWritten.Load(),
+		BatchesRead:      conn.stats.batchesRead.Load(),
+		BatchesWritten:   conn.stats.batchesWritten.Load(),
+		PacketsQueued:    uint64(conn.queueLen()),
+		PacketsDropped:   conn.stats.packetsDropped.Load(),
+		PacketsLimited:   conn.stats.packetsLimited.Load(),
+		BytesBuffered:    conn.stats.bytesBuffered.Load(),
+		WireBytesWritten: conn.stats.wireBytesWritten.Load(),
+	}
+}
@@ -0,0 +1,92 @@
+package minecraft
+
+import (
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// SubClient is a view of a Conn scoped to a single split screen player. Bedrock Edition allows up to four
+// players to play on one device and share a single connection, each identified by a sub client ID between 0
+// and 3, where 0 is the primary player. A SubClient writes packets with its ID in their header and reads only
+// the packets that are meant for its player.
+// A SubClient may be obtained using Conn.SubClient.
+type SubClient struct {
+	conn *Conn
+	id   byte
+}
+
+// SubClient returns a SubClient for the split screen player with the ID passed, which must be between 0 and 3.
+// SubClient panics if the ID is out of range.
+// When SubClient is first called, the Conn starts reading all packets itself and passes each of them to the
+// SubClient of the player it is meant for. From then on, packets must be read using SubClient.ReadPacket
+// rather than using the read methods of the Conn, and no read deadline should be set on the Conn. Each
+// SubClient buffers a limited amount of packets, so the packets of every SubClient must be read, including
+// those of the primary player, to prevent the other players from being blocked.
+func (conn *Conn) SubClient(id byte) *SubClient {
+	if id > 3 {
+		panic(fmt.Sprintf("sub client ID %v out of range: must be between 0 and 3", id))
+	}
+	conn.subClientOnce.Do(func() {
+		for i := range conn.subClients {
+			conn.subClients[i] = make(chan packet.Packet, 64)
+		}
+		go conn.routeSubClients()
+	})
+	return &SubClient{conn: conn, id: id}
+}
+
+// ID returns the sub client ID of the player of the SubClient, which is between 0 and 3.
+func (s *SubClient) ID() byte {
+	return s.id
+}
+
+// Conn returns the Conn that the SubClient is part of.
+func (s *SubClient) Conn() *Conn {
+	return s.conn
+}
+
+// WritePacket encodes the packet passed and writes it to the Conn with the ID of the SubClient in its header.
+// For connections accepted by a Listener, the ID is set as the target of the packet, and for connections
+// obtained using a Dialer, the ID is set as the sender of the packet.
+func (s *SubClient) WritePacket(pk packet.Packet) error {
+	if s.conn.server {
+		return s.conn.WritePacketTo(pk, 0, s.id)
+	}
+	return s.conn.WritePacketTo(pk, s.id, 0)
+}
+
+// ReadPacket reads the next packet meant for the player of the SubClient. For connections accepted by a
+// Listener, these are the packets sent by the player, and for connections obtained using a Dialer, these are
+// the packets targeted at the player.
+func (s *SubClient) ReadPacket() (packet.Packet, error) {
+	select {
+	case pk := <-s.conn.subClients[s.id]:
+		return pk, nil
+	case <-s.conn.ctx.Done():
+		return nil, s.conn.closeErr("read packet")
+	}
+}
+
+// routeSubClients reads packets from the Conn and passes each of them to the channel of the sub client that
+// the packet is meant for, until the Conn is closed. Packets with an invalid sub client ID are dropped.
+func (conn *Conn) routeSubClients() {
+	for {
+		pk, h, err := conn.ReadPacketHeader()
+		if err != nil {
+			return
+		}
+		id := h.TargetSubClient
+		if conn.server {
+			id = h.SenderSubClient
+		}
+		if id > 3 {
+			conn.log.Debug("route sub client: dropping packet with invalid sub client ID", "ID", h.PacketID, "subClient", id)
+			continue
+		}
+		select {
+		case conn.subClients[id] <- pk:
+		case <-conn.ctx.Done():
+			return
+		}
+	}
+}
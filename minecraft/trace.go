@@ -0,0 +1,127 @@
+package minecraft
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"io"
+	"maps"
+	"net"
+	"sync"
+	"time"
+)
+
+// TracePackets returns a function that may be set to ListenConfig.PacketFunc or Dialer.PacketFunc to write a
+// trace of all packets read and written by connections to w. For every packet, one line holding a JSON
+// encoded PacketTrace is written to w, so that the trace may be used to build timelines of packets and to
+// replay the packets by decoding their payloads again using a TraceReader.
+// The function returned may be called from multiple goroutines simultaneously. Writes to w are not buffered,
+// so a bufio.Writer may be used for w to reduce overhead. If writing to w fails, no more traces are written.
+// As with PacketFunc, no tracing overhead exists at all if TracePackets is not used.
+func TracePackets(w io.Writer) func(header packet.Header, payload []byte, src, dst net.Addr) {
+	var (
+		mu     sync.Mutex
+		enc    = json.NewEncoder(w)
+		failed bool
+		start  = time.Now()
+	)
+	return func(header packet.Header, payload []byte, src, dst net.Addr) {
+		// Sub uses the monotonic clock readings of now and start, so the offset is not affected by changes
+		// of the wall clock.
+		now := time.Now()
+		t := PacketTrace{
+			Time:            now,
+			Offset:          now.Sub(start),
+			PacketID:        header.PacketID,
+			SenderSubClient: header.SenderSubClient,
+			TargetSubClient: header.TargetSubClient,
+			Source:          src.String(),
+			Destination:     dst.String(),
+			Size:            len(payload),
+			Payload:         payload,
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failed {
+			return
+		}
+		failed = enc.Encode(t) != nil
+	}
+}
+
+// PacketTrace is a trace of a single packet read from or written to a connection, as written by the function
+// returned by TracePackets.
+type PacketTrace struct {
+	// Time is the time at which the packet was read or written.
+	Time time.Time `json:"time"`
+	// Offset is the duration between the creation of the tracer and the packet being read or written. Unlike
+	// Time, it is obtained from a monotonic clock, so it is suitable for measuring the time between packets.
+	Offset time.Duration `json:"offset"`
+	// PacketID, SenderSubClient and TargetSubClient are the fields of the packet.Header of the packet.
+	PacketID        uint32 `json:"id"`
+	SenderSubClient byte   `json:"sender_sub_client"`
+	TargetSubClient byte   `json:"target_sub_client"`
+	// Source and Destination are the addresses that the packet was sent from and to. Source is the local
+	// address of the connection if the packet was written and the remote address if the packet was read.
+	Source      string `json:"src"`
+	Destination string `json:"dst"`
+	// Size is the size of the payload of the packet in bytes, excluding the header.
+	Size int `json:"size"`
+	// Payload is the raw payload of the packet, excluding the header. It may be decoded again using the
+	// packet.Packet with the ID PacketID, such as by passing it to Packet.Marshal with a protocol.Reader.
+	Payload []byte `json:"payload"`
+}
+
+// TraceReader reads the packets from a trace written by the function returned by TracePackets, so that the
+// packets of a captured session may be replayed and decoded again, for example to reproduce a bug.
+// Payloads are decoded using the latest protocol. Traces of connections using a different protocol, such as
+// those with a Dialer.Protocol set, may therefore not be decoded correctly.
+type TraceReader struct {
+	dec      *json.Decoder
+	pool     packet.Pool
+	shieldID int32
+}
+
+// NewTraceReader creates a TraceReader that reads a trace from r. Packets are decoded by looking up their ID
+// in the packet.Pool passed, and packets with an ID not present in it are returned as a *packet.Unknown. If
+// pool is nil, a pool holding all packets sent by both the client and the server is used. shieldID is the
+// item runtime ID of the shield, which is needed to decode some packets holding items. It is found in the
+// StartGame packet of the session.
+func NewTraceReader(r io.Reader, pool packet.Pool, shieldID int32) *TraceReader {
+	if pool == nil {
+		pool = packet.NewServerPool()
+		maps.Copy(pool, packet.NewClientPool())
+	}
+	return &TraceReader{dec: json.NewDecoder(r), pool: pool, shieldID: shieldID}
+}
+
+// ReadPacket reads the next PacketTrace from the trace and decodes its payload into a packet. io.EOF is
+// returned once the end of the trace is reached. If the payload of a packet could not be decoded, the
+// PacketTrace is returned along with an error and the next call to ReadPacket continues with the next packet.
+func (r *TraceReader) ReadPacket() (pk packet.Packet, t PacketTrace, err error) {
+	if err := r.dec.Decode(&t); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, t, io.EOF
+		}
+		return nil, t, fmt.Errorf("read packet trace: %w", err)
+	}
+	if pkFunc, ok := r.pool[t.PacketID]; ok {
+		pk = pkFunc()
+	} else {
+		pk = &packet.Unknown{PacketID: t.PacketID}
+	}
+	defer func() {
+		if recoveredErr := recover(); recoveredErr != nil {
+			pk, err = nil, fmt.Errorf("decode packet %T: %v", pk, recoveredErr)
+		}
+	}()
+	buf := bytes.NewBuffer(t.Payload)
+	pk.Marshal(protocol.NewReader(buf, r.shieldID, false))
+	if buf.Len() != 0 {
+		return nil, t, fmt.Errorf("decode packet %T: %v unread bytes left: 0x%x", pk, buf.Len(), buf.Bytes())
+	}
+	return pk, t, nil
+}
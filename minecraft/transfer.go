@@ -0,0 +1,93 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+	"net"
+	"strings"
+)
+
+// FollowTransfer connects to the server that the Conn passed was transferred to, using the same network that
+// the Conn was dialed over. The Conn must have been obtained using a Dialer with a non-zero MaxTransfers and
+// must have been closed with a TransferError, which happens when the server sends a packet.Transfer. The
+// Dialer passed is generally the Dialer that dialed the Conn. An error is returned if the Conn was not
+// transferred or if it was obtained through more than MaxTransfers transfers already.
+func (d Dialer) FollowTransfer(ctx context.Context, conn *Conn) (*Conn, error) {
+	var t TransferError
+	if !errors.As(conn.Err(), &t) {
+		return nil, &net.OpError{Op: "follow transfer", Net: "minecraft", Err: fmt.Errorf("connection was not transferred")}
+	}
+	if conn.transfers >= d.MaxTransfers {
+		return nil, &net.OpError{Op: "follow transfer", Net: "minecraft", Err: fmt.Errorf("maximum of %v transfers reached", d.MaxTransfers)}
+	}
+	newConn, err := d.DialContext(ctx, conn.network, t.Addr())
+	if err != nil {
+		return nil, err
+	}
+	newConn.transfers = conn.transfers + 1
+	return newConn, nil
+}
+
+// Transfer transfers the client of the Conn to the server at the address and port passed by sending a
+// packet.Transfer, after which the Conn is closed. The packet is flushed before the Conn is closed, so that
+// the client receives it. address must be an IP address or a hostname and port must not be 0. After Transfer
+// returns, Err returns a TransferError holding the address and port passed.
+func (conn *Conn) Transfer(address string, port uint16) error {
+	if err := validTransferAddress(address); err != nil {
+		return conn.wrap(err, "transfer")
+	}
+	if port == 0 {
+		return conn.wrap(fmt.Errorf("invalid port 0"), "transfer")
+	}
+	if err := conn.WritePacket(&packet.Transfer{Address: address, Port: port}); err != nil {
+		return err
+	}
+	// close flushes the packet before closing the underlying connection.
+	return conn.close(TransferError{Address: address, Port: port})
+}
+
+// maxHostnameLength is the maximum length of a hostname passed to Conn.Transfer.
+const maxHostnameLength = 253
+
+// validTransferAddress checks if the address passed is a valid IP address or hostname to transfer a client to.
+func validTransferAddress(address string) error {
+	if address == "" {
+		return fmt.Errorf("address must not be empty")
+	}
+	if net.ParseIP(address) != nil {
+		return nil
+	}
+	if len(address) > maxHostnameLength {
+		return fmt.Errorf("hostname %q exceeds the maximum length of %v", address, maxHostnameLength)
+	}
+	for _, label := range strings.Split(address, ".") {
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid hostname %q", address)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+				return fmt.Errorf("invalid hostname %q: invalid character %q", address, c)
+			}
+		}
+	}
+	return nil
+}
+
+// transfer closes the Conn with a TransferError holding the address of the Transfer packet held by pkData.
+// If pkData does not hold a packet.Transfer, for example because a custom packet was registered with its ID,
+// false is returned and the packet is not handled, so that it may still be read by the user of the Conn.
+func (conn *Conn) transfer(pkData *packetData) bool {
+	pks, err := pkData.decode(conn)
+	if err != nil || len(pks) == 0 {
+		return false
+	}
+	pk, ok := pks[0].(*packet.Transfer)
+	if !ok {
+		return false
+	}
+	conn.log.Debug("transferred by server", "address", pk.Address, "port", pk.Port)
+	_ = conn.close(TransferError{Address: pk.Address, Port: pk.Port})
+	return true
+}
@@ -0,0 +1,125 @@
+package minecraft
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxFrameSize is the maximum size of a single message read by a framed connection. It prevents a peer from
+// causing very large allocations.
+const maxFrameSize = 1 << 26
+
+// NewStreamConn wraps a message based net.Conn, such as those dialed over RakNet, so that it may be read from
+// like a stream: Read returns the rest of the last message read if b was too small to hold it, rather than
+// discarding it or returning an error. Stream based layers such as TLS may be added on top of the net.Conn
+// returned, after which NewFramedConn restores the message boundaries that a Conn requires:
+//
+//	minecraft.NewFramedConn(tls.Client(minecraft.NewStreamConn(conn), tlsConfig))
+func NewStreamConn(conn net.Conn) net.Conn {
+	return &streamConn{Conn: conn}
+}
+
+// streamConn is a net.Conn that allows a message based net.Conn to be read from like a stream.
+type streamConn struct {
+	net.Conn
+	buf, rest []byte
+}
+
+// Read reads data from the current message into b, reading the next message if the current one was fully
+// read.
+func (c *streamConn) Read(b []byte) (int, error) {
+	if len(c.rest) == 0 {
+		if pr, ok := c.Conn.(interface{ ReadPacket() ([]byte, error) }); ok {
+			msg, err := pr.ReadPacket()
+			if err != nil {
+				return 0, err
+			}
+			c.rest = msg
+		} else {
+			if c.buf == nil {
+				c.buf = make([]byte, maxMessageSize)
+			}
+			n, err := c.Conn.Read(c.buf)
+			if err != nil {
+				return 0, err
+			}
+			c.rest = c.buf[:n]
+		}
+	}
+	n := copy(b, c.rest)
+	c.rest = c.rest[n:]
+	return n, nil
+}
+
+// maxMessageSize is the size of the buffer that messages are read into by a streamConn if the underlying
+// net.Conn has no ReadPacket method. It matches the buffer size used by packet.Decoder.
+const maxMessageSize = 1024 * 1024 * 3
+
+// NewFramedConn wraps a stream based net.Conn, such as a tls.Conn or a net.TCPConn, so that every message
+// written is read back as a whole by a single call to Read on the other end. Messages are written with a
+// 4-byte big endian length prefix. The net.Conn returned may be returned by Dialer.WrapConn and
+// ListenConfig.WrapConn to use such a layer as transport. Both ends of the connection must use
+// NewFramedConn.
+func NewFramedConn(stream net.Conn) net.Conn {
+	return &framedConn{Conn: stream, r: bufio.NewReader(stream)}
+}
+
+// framedConn is a net.Conn that writes and reads length prefixed messages to and from a stream based
+// net.Conn.
+type framedConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// ReadPacket reads the next message from the connection. It implements the interface used by packet.Decoder
+// to read messages without copying them into a buffer first.
+func (c *framedConn) ReadPacket() ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(c.r, prefix[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("read frame: size %v exceeds maximum of %v", n, maxFrameSize)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Read reads the next message from the connection into b. An error is returned if b is too small to hold
+// the message.
+func (c *framedConn) Read(b []byte) (int, error) {
+	msg, err := c.ReadPacket()
+	if err != nil {
+		return 0, err
+	}
+	if len(msg) > len(b) {
+		return 0, errBufferTooSmall
+	}
+	return copy(b, msg), nil
+}
+
+// Write writes b to the connection as a single message.
+func (c *framedConn) Write(b []byte) (int, error) {
+	if len(b) > maxFrameSize {
+		return 0, fmt.Errorf("write frame: size %v exceeds maximum of %v", len(b), maxFrameSize)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = binary.BigEndian.AppendUint32(c.buf[:0], uint32(len(b)))
+	c.buf = append(c.buf, b...)
+	if _, err := c.Conn.Write(c.buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}